@@ -1527,6 +1527,16 @@ Calculates resources that should be monitored during deployment by Deployment St
 
 {{/******************************************************************************************************************/}}
 
+{{/*
+Return "true" when the readiness-probe args read the CA bundle from a ConfigMap with -caConfigMapName,
+including the caConfigMapName option of the -check args, so the Roles allow to read and watch ConfigMaps.
+*/}}
+{{- define "readinessProbe.caConfigMap.enabled" -}}
+  {{- if and .Values.readinessProbe.install (contains "caConfigMapName=" (join " " (.Values.readinessProbe.args | default list))) -}}
+      true
+  {{- end -}}
+{{- end -}}
+
 {{/*
 Prepare args for readiness-probe container.
 */}}
@@ -1546,6 +1556,9 @@ Prepare args for readiness-probe container.
       {{- end }}
             - "-host={{ include "cassandraSchemaJob.host" . }}"
             - "-port={{ include "cassandraSchemaJob.port" . }}"
+      {{- if .Values.readinessProbe.verifySchema }}
+            - "-verifySchema=true"
+      {{- end }}
       {{- if .Values.cassandraSchemaJob.tls.enabled }}
             - "-tlsEnabled=true"
         {{- if .Values.cassandraSchemaJob.tls.insecureSkipVerify }}
@@ -1556,10 +1569,32 @@ Prepare args for readiness-probe container.
             - "-keyPath=/cassandra-tls/client-key.pem"
         {{- end }}
       {{- end }}
-    {{- else }}
-            - "-storage=opensearch"
+    {{- else if eq .Values.jaeger.storage.type "remotegRPC" }}
+            - "-storage=grpc"
+            - "-host={{ .Values.remotegRPC.url | default .Values.remotegRPC.endpoint }}"
+      {{- if .Values.remotegRPC.tls.enabled }}
+            - "-tlsEnabled=true"
+        {{- if .Values.remotegRPC.tls.insecureSkipVerify }}
+            - "-insecureSkipVerify=true"
+        {{- else }}
+            - "-caPath=/grpc-tls/ca-cert.pem"
+            - "-crtPath=/grpc-tls/client-cert.pem"
+            - "-keyPath=/grpc-tls/client-key.pem"
+        {{- end }}
+      {{- end }}
+    {{- else if eq .Values.jaeger.storage.type "elasticsearch" }}
+            - "-storage=elasticsearch"
             - "-host={{ include "elasticsearch.url" . }}"
             - "-authSecretName=jaeger-elasticsearch"
+      {{- if .Values.readinessProbe.verifyIndices }}
+            - "-verifyIndices=true"
+        {{- if .Values.elasticsearch.useAliases }}
+            - "-useAliases=true"
+        {{- end }}
+        {{- if .Values.elasticsearch.indexPrefix }}
+            - "-indexPrefix={{ .Values.elasticsearch.indexPrefix }}"
+        {{- end }}
+      {{- end }}
       {{- if .Values.elasticsearch.client.tls.enabled }}
             - "-tlsEnabled=true"
         {{- if .Values.elasticsearch.client.tls.insecureSkipVerify }}
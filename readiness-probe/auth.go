@@ -0,0 +1,276 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Authentication methods of the -authType parameter
+const (
+	basicAuth          string = "basic"
+	apiKeyAuth         string = "apikey"
+	bearerAuth         string = "bearer"
+	serviceAccountAuth string = "serviceaccount"
+	sigV4Auth          string = "sigv4"
+	noAuth             string = "none"
+)
+
+// Keys of the credentials in the Secret or the names of the credential files
+const (
+	usernameKey        string = "username"
+	passwordKey        string = "password"
+	apiKeyKey          string = "apiKey"
+	tokenKey           string = "token"
+	accessKeyIDKey     string = "accessKeyId"
+	secretAccessKeyKey string = "secretAccessKey"
+	sessionTokenKey    string = "sessionToken"
+)
+
+// defaultTokenPath is the ServiceAccount token mounted into the pod
+const defaultTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// credentialKeys lists the required and the optional credentials of each authentication method
+var credentialKeys = map[string]struct {
+	required []string
+	optional []string
+}{
+	basicAuth:          {required: []string{usernameKey, passwordKey}},
+	apiKeyAuth:         {required: []string{apiKeyKey}},
+	bearerAuth:         {required: []string{tokenKey}},
+	serviceAccountAuth: {},
+	sigV4Auth:          {required: []string{accessKeyIDKey, secretAccessKeyKey}, optional: []string{sessionTokenKey}},
+	noAuth:             {},
+}
+
+func authTypes() []string {
+	types := make([]string, 0, len(credentialKeys))
+	for authType := range credentialKeys {
+		types = append(types, authType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// authCredentials are the values of the credential keys of the authentication method
+type authCredentials struct {
+	user               string
+	password           string
+	apiKey             string
+	token              string
+	awsAccessKeyID     string
+	awsSecretAccessKey string
+	awsSessionToken    string
+}
+
+// setCredential stores the credential read from the Secret or the file
+func (c *authCredentials) setCredential(key string, value string) {
+	switch key {
+	case usernameKey:
+		c.user = value
+	case passwordKey:
+		c.password = value
+	case apiKeyKey:
+		c.apiKey = value
+	case tokenKey:
+		c.token = value
+	case accessKeyIDKey:
+		c.awsAccessKeyID = value
+	case secretAccessKeyKey:
+		c.awsSecretAccessKey = value
+	case sessionTokenKey:
+		c.awsSessionToken = value
+	}
+}
+
+// requestAuthenticator adds the credentials to the request of the HTTP storage
+type requestAuthenticator interface {
+	authenticate(req *http.Request, body []byte) error
+}
+
+// newRequestAuthenticator creates the authenticator of the configured method for the HTTP storages
+func newRequestAuthenticator(cfg *Config) (requestAuthenticator, error) {
+	switch strings.ToLower(cfg.authType) {
+	case "", basicAuth:
+		return &basicAuthenticator{user: cfg.user, password: cfg.password}, nil
+	case apiKeyAuth:
+		return &headerAuthenticator{value: "ApiKey " + cfg.apiKey}, nil
+	case bearerAuth:
+		return &headerAuthenticator{value: "Bearer " + cfg.token}, nil
+	case serviceAccountAuth:
+		return &tokenFileAuthenticator{path: cfg.tokenPath}, nil
+	case noAuth:
+		return &anonymousAuthenticator{}, nil
+	case sigV4Auth:
+		if cfg.awsRegion == "" {
+			return nil, fmt.Errorf("missing required argument -awsRegion for -authType=%s", sigV4Auth)
+		}
+		return &sigV4Authenticator{
+			accessKeyID:     cfg.awsAccessKeyID,
+			secretAccessKey: cfg.awsSecretAccessKey,
+			sessionToken:    cfg.awsSessionToken,
+			region:          cfg.awsRegion,
+			service:         cfg.awsService,
+			now:             time.Now,
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown -authType '%s', possible values: %s", cfg.authType, strings.Join(authTypes(), ", "))
+}
+
+type basicAuthenticator struct {
+	user     string
+	password string
+}
+
+func (a *basicAuthenticator) authenticate(req *http.Request, _ []byte) error {
+	req.SetBasicAuth(a.user, a.password)
+	return nil
+}
+
+// anonymousAuthenticator sends the requests without the credentials to the storage without authentication
+type anonymousAuthenticator struct{}
+
+func (a *anonymousAuthenticator) authenticate(_ *http.Request, _ []byte) error {
+	return nil
+}
+
+// headerAuthenticator sets the static Authorization header, for example the API key or the bearer token
+type headerAuthenticator struct {
+	value string
+}
+
+func (a *headerAuthenticator) authenticate(req *http.Request, _ []byte) error {
+	req.Header.Set("Authorization", a.value)
+	return nil
+}
+
+// tokenFileAuthenticator reads the bearer token for every request, because the projected token is rotated by kubelet
+type tokenFileAuthenticator struct {
+	path string
+}
+
+func (a *tokenFileAuthenticator) authenticate(req *http.Request, _ []byte) error {
+	data, err := os.ReadFile(a.path)
+	if err != nil {
+		return fmt.Errorf("can't read the ServiceAccount token: %w", err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return fmt.Errorf("the ServiceAccount token '%s' is empty", a.path)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// sigV4Authenticator signs the request with AWS Signature Version 4 for the managed OpenSearch domains
+type sigV4Authenticator struct {
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+	region          string
+	service         string
+	now             func() time.Time
+}
+
+func (a *sigV4Authenticator) authenticate(req *http.Request, body []byte) error {
+	now := a.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if a.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", a.sessionToken)
+	}
+	// OpenSearch Serverless requires the signed payload hash
+	if a.service == "aoss" {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.Join(values, ",")
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		awsEscape(req.URL.EscapedPath(), false),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + a.region + "/" + a.service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+a.secretAccessKey), date)
+	key = hmacSHA256(key, a.region)
+	key = hmacSHA256(key, a.service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		a.accessKeyID, scope, signedHeaders, signature))
+	return nil
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery returns the query sorted by the names and the values with the AWS encoding
+func canonicalQuery(query url.Values) string {
+	var params []string
+	for name, values := range query {
+		for _, value := range values {
+			params = append(params, awsEscape(name, true)+"="+awsEscape(value, true))
+		}
+	}
+	sort.Strings(params)
+	return strings.Join(params, "&")
+}
+
+// awsEscape encodes all characters except the unreserved ones as required by the AWS signature
+func awsEscape(value string, escapeSlash bool) string {
+	var b strings.Builder
+	for _, c := range []byte(value) {
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !escapeSlash) {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
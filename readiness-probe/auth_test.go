@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSigV4_GetVanilla(t *testing.T) {
+	// The get-vanilla request of the AWS Signature Version 4 test suite
+	req, _ := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	auth := &sigV4Authenticator{
+		accessKeyID:     "AKIDEXAMPLE",
+		secretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		region:          "us-east-1",
+		service:         "service",
+		now:             func() time.Time { return time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC) },
+	}
+	if err := auth.authenticate(req, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, " +
+		"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Fatalf("unexpected Authorization header:\n got %s\nwant %s", got, want)
+	}
+	if req.Header.Get("X-Amz-Date") != "20150830T123600Z" {
+		t.Errorf("unexpected X-Amz-Date: %s", req.Header.Get("X-Amz-Date"))
+	}
+}
+
+func TestSigV4_SessionTokenAndServerless(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPut, "https://collection.aoss.amazonaws.com/index/_doc/1?refresh=true", nil)
+	auth := &sigV4Authenticator{
+		accessKeyID:     "AKID",
+		secretAccessKey: "secret",
+		sessionToken:    "session",
+		region:          "eu-west-1",
+		service:         "aoss",
+		now:             time.Now,
+	}
+	if err := auth.authenticate(req, []byte(`{}`)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if req.Header.Get("X-Amz-Security-Token") != "session" {
+		t.Errorf("expected the session token header")
+	}
+	if req.Header.Get("X-Amz-Content-Sha256") != sha256Hex([]byte(`{}`)) {
+		t.Errorf("expected the payload hash header, got %q", req.Header.Get("X-Amz-Content-Sha256"))
+	}
+	if !strings.Contains(req.Header.Get("Authorization"), "SignedHeaders=host;x-amz-content-sha256;x-amz-date;x-amz-security-token,") {
+		t.Errorf("unexpected signed headers: %s", req.Header.Get("Authorization"))
+	}
+}
+
+func TestAwsEscape(t *testing.T) {
+	if got := awsEscape("/a b/ü~", false); got != "/a%20b/%C3%BC~" {
+		t.Errorf("unexpected path escaping: %s", got)
+	}
+	if got := canonicalQuery(map[string][]string{"b": {"2"}, "a": {"x/y", "1"}}); got != "a=1&a=x%2Fy&b=2" {
+		t.Errorf("unexpected canonical query: %s", got)
+	}
+}
+
+func TestRequestAuthenticator_Headers(t *testing.T) {
+	tokenPath := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenPath, []byte("sa-token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		cfg  Config
+		want string
+	}{
+		{Config{authType: basicAuth, authCredentials: authCredentials{user: "u", password: "p"}}, "Basic dTpw"},
+		{Config{authType: apiKeyAuth, authCredentials: authCredentials{apiKey: "a2V5"}}, "ApiKey a2V5"},
+		{Config{authType: bearerAuth, authCredentials: authCredentials{token: "static"}}, "Bearer static"},
+		{Config{authType: serviceAccountAuth, tokenPath: tokenPath}, "Bearer sa-token"},
+	}
+	for _, tt := range tests {
+		auth, err := newRequestAuthenticator(&tt.cfg)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.cfg.authType, err)
+		}
+		req, _ := http.NewRequest(http.MethodGet, "http://opensearch:9200/", nil)
+		if err := auth.authenticate(req, nil); err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.cfg.authType, err)
+		}
+		if got := req.Header.Get("Authorization"); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.cfg.authType, tt.want, got)
+		}
+	}
+}
+
+func TestRequestAuthenticator_Errors(t *testing.T) {
+	if _, err := newRequestAuthenticator(&Config{authType: sigV4Auth}); err == nil || !strings.Contains(err.Error(), "-awsRegion") {
+		t.Errorf("expected missing region error, got %v", err)
+	}
+	if _, err := newRequestAuthenticator(&Config{authType: "kerberos"}); err == nil || !strings.Contains(err.Error(), "possible values") {
+		t.Errorf("expected unknown type error, got %v", err)
+	}
+	auth := &tokenFileAuthenticator{path: filepath.Join(t.TempDir(), "missing")}
+	req, _ := http.NewRequest(http.MethodGet, "http://opensearch:9200/", nil)
+	if err := auth.authenticate(req, nil); err == nil {
+		t.Errorf("expected error for the missing token file")
+	}
+}
+
+func TestOpensearchHealth_BearerAuth(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer static" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(greenClusterHealth))
+	}))
+	defer srv.Close()
+
+	checker, err := newOpensearchChecker(&Config{
+		hosts:            hostList{srv.URL},
+		authType:         bearerAuth,
+		authCredentials:  authCredentials{token: "static"},
+		minClusterStatus: "green",
+		errorsCount:      1,
+		retryCount:       1,
+		timeout:          1,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res := checker.Check(context.Background()); !res.Healthy {
+		t.Fatalf("expected healthy result, got %v", res.Err)
+	}
+}
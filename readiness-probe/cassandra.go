@@ -0,0 +1,449 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+const cassandra string = "cassandra"
+
+// CassandraSession interface for mocking
+type CassandraSession interface {
+	Query(stmt string, values ...interface{}) Query
+	Close()
+}
+
+// Query interface for mocking
+type Query interface {
+	// WithContext returns the query which is cancelled with the context
+	WithContext(ctx context.Context) Query
+	Exec() error
+	Scan(dest ...interface{}) error
+	Rows() ([]map[string]interface{}, error)
+}
+
+// Real implementations that wrap gocql types
+type realCassandraSession struct {
+	session *gocql.Session
+}
+
+func (r *realCassandraSession) Query(stmt string, values ...interface{}) Query {
+	return &realQuery{query: r.session.Query(stmt, values...)}
+}
+
+func (r *realCassandraSession) Close() {
+	r.session.Close()
+}
+
+type realQuery struct {
+	query *gocql.Query
+}
+
+func (r *realQuery) WithContext(ctx context.Context) Query {
+	return &realQuery{query: r.query.WithContext(ctx)}
+}
+
+func (r *realQuery) Exec() error {
+	return r.query.Exec()
+}
+
+func (r *realQuery) Scan(dest ...interface{}) error {
+	return r.query.Scan(dest...)
+}
+
+func (r *realQuery) Rows() ([]map[string]interface{}, error) {
+	return r.query.Iter().SliceMap()
+}
+
+type cassandraChecker struct {
+	// mu guards the session which is rebuilt when the credentials or the certificates are changed,
+	// it is held only to read or swap the session, so the rebuild doesn't wait for the running check
+	mu      sync.Mutex
+	session CassandraSession
+	// checking is the session used by the running check, it is closed after the check when it is replaced
+	checking CassandraSession
+	connect  func(user string, password string) (*gocql.Session, error)
+	// reconnectMu serializes the rebuilds of the session and guards the credentials of the session
+	reconnectMu sync.Mutex
+	user        string
+	password    string
+	certs       *certReloader
+
+	endpoint    string
+	errorsCount int
+	retry       backoff
+	keyspace    string
+	testTable   string
+
+	verifySchema  bool
+	schemaVersion string
+
+	verifyTopology  bool
+	minLiveReplicas int
+	topologyPolicy  string
+	nativePort      int
+	nodeTimeout     time.Duration
+	dial            dialFunc
+
+	writeCheck      bool
+	writeCheckTable string
+	writeCheckTTL   time.Duration
+	createTable     bool
+	tableCreated    bool
+}
+
+func init() {
+	registerChecker(cassandra, newCassandraChecker)
+}
+
+// cassandraClusterOptions contains the validated parameters of the Cassandra driver
+type cassandraClusterOptions struct {
+	consistency       gocql.Consistency
+	protoVersion      int
+	numConns          int
+	initialHostLookup bool
+	tokenAware        bool
+	// tlsConfig enables TLS with the certificates reloaded from the files
+	tlsConfig *tls.Config
+}
+
+// Host selection policies of the Cassandra driver
+const (
+	dcAwarePolicy    string = "dcaware"
+	tokenAwarePolicy string = "tokenaware"
+)
+
+// parseCassandraOptions validates the Cassandra driver parameters
+func parseCassandraOptions(cfg *Config) (cassandraClusterOptions, error) {
+	opts := cassandraClusterOptions{
+		protoVersion:      cfg.protoVersion,
+		numConns:          cfg.numConns,
+		initialHostLookup: cfg.initialHostLookup,
+	}
+	consistency, err := gocql.ParseConsistencyWrapper(cfg.consistency)
+	if err != nil {
+		return opts, fmt.Errorf("invalid -consistency '%s', possible values: ANY, ONE, TWO, THREE, QUORUM, ALL, LOCAL_QUORUM, EACH_QUORUM, LOCAL_ONE", cfg.consistency)
+	}
+	opts.consistency = consistency
+	switch cfg.protoVersion {
+	case 0, 3, 4, 5:
+	default:
+		return opts, fmt.Errorf("invalid -protoVersion %d, possible values: 3, 4, 5 or 0 for the version discovery", cfg.protoVersion)
+	}
+	if cfg.numConns < 1 {
+		return opts, fmt.Errorf("invalid -numConns %d, must be at least 1", cfg.numConns)
+	}
+	switch strings.ToLower(cfg.hostSelectionPolicy) {
+	case dcAwarePolicy:
+	case tokenAwarePolicy:
+		// The token ring is known only from the discovered peers
+		if !cfg.initialHostLookup {
+			return opts, fmt.Errorf("-hostSelectionPolicy=%s requires -initialHostLookup=true", cfg.hostSelectionPolicy)
+		}
+		opts.tokenAware = true
+	default:
+		return opts, fmt.Errorf("invalid -hostSelectionPolicy '%s', possible values: %s, %s", cfg.hostSelectionPolicy, dcAwarePolicy, tokenAwarePolicy)
+	}
+	return opts, nil
+}
+
+func newCassandraChecker(cfg *Config) (HealthChecker, error) {
+	checker, opts, err := configureCassandraChecker(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.tlsEnabled {
+		opts.tlsConfig, checker.certs = createTLSConfig(cfg.tlsOptions())
+	}
+	session := createCassandraClient(cfg.hosts, cfg.port, cfg.user, cfg.password, time.Duration(cfg.timeout), cfg.errorsCount, cfg.datacenter, cfg.keyspace, opts)
+	checker.session = &realCassandraSession{session: session}
+	checker.connect = func(user string, password string) (*gocql.Session, error) {
+		cluster := newCassandraCluster(cfg.hosts, cfg.port, user, password, time.Duration(cfg.timeout), cfg.datacenter, cfg.keyspace, opts)
+		return createSessionWithRetry(cluster, cfg.errorsCount, time.Second)
+	}
+	if checker.certs != nil {
+		// The open connections keep the previous certificates until the session is rebuilt
+		checker.certs.onChange(checker.reconnect)
+	}
+	return checker, nil
+}
+
+// configureCassandraChecker validates the configuration and returns the checker without the session
+// and the options of the driver for creating the session
+func configureCassandraChecker(cfg *Config) (*cassandraChecker, cassandraClusterOptions, error) {
+	opts, err := parseCassandraOptions(cfg)
+	if err != nil {
+		return nil, opts, err
+	}
+	if cfg.authType != "" && !strings.EqualFold(cfg.authType, basicAuth) && !strings.EqualFold(cfg.authType, noAuth) {
+		return nil, opts, fmt.Errorf("storage '%s' supports only -authType=%s or -authType=%s", cassandra, basicAuth, noAuth)
+	}
+	topologyPolicy := strings.ToLower(cfg.topologyPolicy)
+	if topologyPolicy != topologyFail && topologyPolicy != topologyDegrade {
+		return nil, opts, fmt.Errorf("invalid -topologyPolicy '%s', possible values: %s, %s", cfg.topologyPolicy, topologyFail, topologyDegrade)
+	}
+	nativePort := cfg.port
+	if nativePort == 0 {
+		nativePort = 9042
+	}
+	return &cassandraChecker{
+		user:        cfg.user,
+		password:    cfg.password,
+		endpoint:    cfg.endpoint(),
+		errorsCount: cfg.errorsCount,
+		retry:       cfg.retryBackoff(),
+		keyspace:    cfg.keyspace,
+		testTable:   cfg.testTable,
+
+		verifySchema: cfg.verifySchema,
+
+		verifyTopology:  cfg.verifyTopology,
+		minLiveReplicas: cfg.minLiveReplicas,
+		topologyPolicy:  topologyPolicy,
+		nativePort:      nativePort,
+		nodeTimeout:     time.Duration(cfg.timeout) * time.Second,
+
+		writeCheck:      cfg.writeCheck,
+		writeCheckTable: cfg.writeCheckTable,
+		writeCheckTTL:   time.Duration(cfg.writeCheckTTL) * time.Second,
+		createTable:     cfg.createWriteCheckTable,
+	}, opts, nil
+}
+
+func (c *cassandraChecker) Check(ctx context.Context) Result {
+	session := c.acquireSession()
+	defer c.releaseSession(session)
+	start := time.Now()
+	attempts, err := c.health(ctx, session)
+	if err == nil && c.verifySchema {
+		err = c.verifyJaegerSchema(ctx, session)
+	}
+	var details map[string]interface{}
+	var degradedErr error
+	if err == nil && c.verifyTopology {
+		var topology *topologyState
+		topology, err = c.checkTopology(ctx, session)
+		if topology != nil {
+			details = map[string]interface{}{"topology": topology}
+			// Only the missing replicas degrade the storage, the errors of the queries fail it
+			if err != nil && c.topologyPolicy == topologyDegrade {
+				degradedErr, err = err, nil
+			}
+		}
+	}
+	var steps map[string]time.Duration
+	if err == nil && c.writeCheck {
+		steps, err = c.writeCanary(ctx, session)
+	}
+	if err == nil && degradedErr != nil {
+		slog.Warn(fmt.Sprintf("Cassandra is degraded: %s", degradedErr.Error()))
+		return Result{
+			Healthy:  true,
+			Degraded: true,
+			Backend:  cassandra,
+			Endpoint: c.endpoint,
+			Attempts: attempts,
+			Latency:  time.Since(start),
+			Err:      degradedErr,
+			Steps:    steps,
+			Details:  details,
+		}
+	}
+	return Result{
+		Healthy:  err == nil,
+		Backend:  cassandra,
+		Endpoint: c.endpoint,
+		Attempts: attempts,
+		Latency:  time.Since(start),
+		Err:      err,
+		Steps:    steps,
+		Details:  details,
+	}
+}
+
+func (c *cassandraChecker) certificates() *certReloader {
+	return c.certs
+}
+
+func (c *cassandraChecker) Close() {
+	if c.certs != nil {
+		c.certs.close()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.session != nil {
+		c.session.Close()
+	}
+}
+
+// acquireSession returns the current session for the check
+func (c *cassandraChecker) acquireSession() CassandraSession {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checking = c.session
+	return c.session
+}
+
+// releaseSession closes the session of the finished check if it was replaced during the check
+func (c *cassandraChecker) releaseSession(session CassandraSession) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checking = nil
+	if session != nil && session != c.session {
+		session.Close()
+	}
+}
+
+// updateCredentials creates the session with the new credentials and closes the previous one,
+// the previous session is kept when the new one can't be created
+func (c *cassandraChecker) updateCredentials(creds authCredentials) error {
+	c.reconnectMu.Lock()
+	defer c.reconnectMu.Unlock()
+	if err := c.rebuildSession(creds.user, creds.password); err != nil {
+		return fmt.Errorf("can't create session with the updated credentials: %w", err)
+	}
+	c.user, c.password = creds.user, creds.password
+	return nil
+}
+
+// reconnect rebuilds the session with the current credentials after the certificates are reloaded
+func (c *cassandraChecker) reconnect() {
+	c.reconnectMu.Lock()
+	defer c.reconnectMu.Unlock()
+	if err := c.rebuildSession(c.user, c.password); err != nil {
+		slog.Error(fmt.Sprintf("Can't create session with the reloaded certificates: %s", err.Error()))
+		return
+	}
+	slog.Info("Cassandra session is rebuilt with the reloaded certificates")
+}
+
+// rebuildSession swaps the session, the previous session is closed after the swap or after the check which uses it
+func (c *cassandraChecker) rebuildSession(user string, password string) error {
+	if c.connect == nil {
+		return fmt.Errorf("%s checker can't rebuild the session", cassandra)
+	}
+	session, err := c.connect(user, password)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	previous := c.session
+	c.session = &realCassandraSession{session: session}
+	// The session of the running check is closed when the check is finished
+	inUse := previous == c.checking
+	c.mu.Unlock()
+	if previous != nil && !inUse {
+		previous.Close()
+	}
+	return nil
+}
+
+// health returns the number of made attempts and the last error, nil error means the storage is healthy
+func (c *cassandraChecker) health(ctx context.Context, session CassandraSession) (int, error) {
+	errors := 0
+	err := fmt.Errorf("cassandra session is not initialized")
+	for errors < c.errorsCount {
+		if session != nil {
+			query := session.Query(fmt.Sprintf("SELECT * FROM %s.%s limit 1;", c.keyspace, c.testTable))
+			if query != nil {
+				err = query.WithContext(ctx).Exec()
+				if err != nil {
+					slog.Error("Can't select from table. The error from server: ", "error", err.Error())
+				} else {
+					return errors + 1, nil
+				}
+			}
+		}
+		errors += 1
+		slog.Info(fmt.Sprintf("Remaining attempts: %d", c.errorsCount-errors))
+		if errors >= c.errorsCount {
+			return errors, err
+		}
+		delay := c.retry.delay(errors)
+		slog.Info(fmt.Sprintf("Sleep for %s and try again", delay.Round(time.Millisecond)))
+		if waitErr := sleepContext(ctx, delay); waitErr != nil {
+			return errors, interrupted(waitErr, err)
+		}
+	}
+	return errors, err
+}
+
+func createCassandraClient(hosts []string, port int, user string, password string, timeout time.Duration, errorsCount int, datacenter string, keyspace string, opts cassandraClusterOptions) *gocql.Session {
+	cluster := newCassandraCluster(hosts, port, user, password, timeout, datacenter, keyspace, opts)
+	session, err := createSessionWithRetry(cluster, errorsCount, time.Second)
+	if err != nil {
+		slog.Error(fmt.Sprintf("Can't create session: %s", err.Error()))
+		os.Exit(1)
+	}
+	return session
+}
+
+// tlsHostDialer verifies the host of every connection, because the driver doesn't set the server name for the configuration
+// which skips the standard verification, and the host name is verified by the reloaded configuration itself.
+type tlsHostDialer struct {
+	dialer    *net.Dialer
+	tlsConfig *tls.Config
+}
+
+func (d *tlsHostDialer) DialHost(ctx context.Context, host *gocql.HostInfo) (*gocql.DialedHost, error) {
+	conn, err := d.dialer.DialContext(ctx, "tcp", host.ConnectAddressAndPort())
+	if err != nil {
+		return nil, err
+	}
+	addr := host.HostnameAndPort()
+	hostname, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return gocql.WrapTLS(ctx, conn, addr, tlsConfigFor(d.tlsConfig, hostname))
+}
+
+func newCassandraCluster(hosts []string, port int, user string, password string, timeout time.Duration, datacenter string, keyspace string, opts cassandraClusterOptions) *gocql.ClusterConfig {
+	cluster := gocql.NewCluster(hosts...)
+	cluster.Port = port
+	cluster.Keyspace = keyspace
+	cluster.ConnectTimeout = time.Second * timeout
+	cluster.NumConns = opts.numConns
+	if opts.tlsConfig != nil {
+		cluster.HostDialer = &tlsHostDialer{dialer: &net.Dialer{Timeout: cluster.ConnectTimeout}, tlsConfig: opts.tlsConfig}
+	}
+	// The anonymous session is created for the cluster with AllowAllAuthenticator
+	if user != "" {
+		cluster.Authenticator = gocql.PasswordAuthenticator{
+			Username: user,
+			Password: password,
+		}
+	}
+	cluster.PoolConfig.HostSelectionPolicy = gocql.DCAwareRoundRobinPolicy(datacenter)
+	if opts.tokenAware {
+		cluster.PoolConfig.HostSelectionPolicy = gocql.TokenAwareHostPolicy(cluster.PoolConfig.HostSelectionPolicy)
+	}
+	cluster.ProtoVersion = opts.protoVersion
+	cluster.Consistency = opts.consistency
+	cluster.DisableInitialHostLookup = !opts.initialHostLookup
+	return cluster
+}
+
+func createSessionWithRetry(cluster *gocql.ClusterConfig, maxRetries int, retryDelay time.Duration) (*gocql.Session, error) {
+	var lastErr error
+	for i := 1; i <= maxRetries; i++ {
+		session, err := cluster.CreateSession()
+		if err == nil {
+			return session, nil
+		}
+		lastErr = err
+		slog.Error("Failed to create Cassandra session", "attempt", i, "err", err)
+		time.Sleep(retryDelay)
+	}
+	return nil, fmt.Errorf("failed to create Cassandra session after %d attempts: %w", maxRetries, lastErr)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+)
+
+// Tables which Jaeger needs in the keyspace
+var jaegerTables = []string{
+	"traces",
+	"service_names",
+	"operation_names_v2",
+	"service_operation_index",
+	"duration_index",
+	"tag_index",
+	"dependencies_v2",
+	"sampling_probabilities",
+}
+
+// jaegerSchemaVersions lists the Jaeger schema versions from the newest with the table which appeared in the version
+var jaegerSchemaVersions = []struct {
+	version string
+	table   string
+}{
+	{"v004", "sampling_probabilities"},
+	{"v003", "operation_names_v2"},
+	{"v002", "dependencies_v2"},
+	{"v001", "traces"},
+}
+
+// detectSchemaVersion returns the Jaeger schema version by the existing tables
+func detectSchemaVersion(tables map[string]bool) string {
+	for _, v := range jaegerSchemaVersions {
+		if tables[v.table] {
+			return v.version
+		}
+	}
+	return "unknown"
+}
+
+// verifyJaegerSchema checks that the keyspace has all Jaeger tables and all nodes agree on the schema version
+func (c *cassandraChecker) verifyJaegerSchema(ctx context.Context, session CassandraSession) error {
+	rows, err := session.Query("SELECT table_name FROM system_schema.tables WHERE keyspace_name = ?;", c.keyspace).WithContext(ctx).Rows()
+	if err != nil {
+		return fmt.Errorf("can't read tables of keyspace '%s': %w", c.keyspace, err)
+	}
+	tables := map[string]bool{}
+	for _, row := range rows {
+		if name, ok := row["table_name"].(string); ok {
+			tables[name] = true
+		}
+	}
+	version := detectSchemaVersion(tables)
+	if version != c.schemaVersion {
+		slog.Info(fmt.Sprintf("Jaeger schema %s is detected in keyspace '%s'", version, c.keyspace))
+		c.schemaVersion = version
+	}
+	var missing []string
+	for _, table := range jaegerTables {
+		if !tables[table] {
+			missing = append(missing, table)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("keyspace '%s' with Jaeger schema %s is missing tables: %s", c.keyspace, version, strings.Join(missing, ", "))
+	}
+	return c.verifySchemaAgreement(ctx, session)
+}
+
+// verifySchemaAgreement compares the schema version of the coordinator from system.local with the versions of its peers
+func (c *cassandraChecker) verifySchemaAgreement(ctx context.Context, session CassandraSession) error {
+	local, err := session.Query("SELECT schema_version FROM system.local WHERE key = 'local';").WithContext(ctx).Rows()
+	if err != nil {
+		return fmt.Errorf("can't read schema version from system.local: %w", err)
+	}
+	peers, err := session.Query("SELECT peer, schema_version FROM system.peers;").WithContext(ctx).Rows()
+	if err != nil {
+		return fmt.Errorf("can't read schema versions from system.peers: %w", err)
+	}
+	nodes := map[string][]string{}
+	for _, row := range local {
+		nodes[fmt.Sprint(row["schema_version"])] = append(nodes[fmt.Sprint(row["schema_version"])], "local")
+	}
+	for _, row := range peers {
+		// The peers which are not fully joined have no schema version and are skipped like in the driver
+		if row["schema_version"] == nil || fmt.Sprint(row["schema_version"]) == "" {
+			continue
+		}
+		version := fmt.Sprint(row["schema_version"])
+		nodes[version] = append(nodes[version], fmt.Sprint(row["peer"]))
+	}
+	if len(nodes) <= 1 {
+		return nil
+	}
+	versions := make([]string, 0, len(nodes))
+	for version, hosts := range nodes {
+		versions = append(versions, fmt.Sprintf("%s on %s", version, strings.Join(hosts, ", ")))
+	}
+	sort.Strings(versions)
+	return fmt.Errorf("nodes disagree on the schema version: %s", strings.Join(versions, "; "))
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/gocql/gocql"
+)
+
+var (
+	schemaVersion1 = gocql.MustRandomUUID()
+	schemaVersion2 = gocql.MustRandomUUID()
+)
+
+func tableRows(tables ...string) []map[string]interface{} {
+	rows := make([]map[string]interface{}, 0, len(tables))
+	for _, table := range tables {
+		rows = append(rows, map[string]interface{}{"table_name": table})
+	}
+	return rows
+}
+
+func newSchemaSession(tables []map[string]interface{}, peers []map[string]interface{}) *mockCassandraSession {
+	return &mockCassandraSession{rows: map[string][]map[string]interface{}{
+		"SELECT table_name FROM system_schema.tables":   tables,
+		"SELECT schema_version FROM system.local":       {{"schema_version": schemaVersion1}},
+		"SELECT peer, schema_version FROM system.peers": peers,
+	}}
+}
+
+func newSchemaChecker(t *testing.T, session *mockCassandraSession) *cassandraChecker {
+	return newTestCassandraChecker(t, session, "-errors=1", "-verifySchema=true")
+}
+
+func TestVerifyJaegerSchema_Success(t *testing.T) {
+	session := newSchemaSession(tableRows(append([]string{"leases", "operation_throughput"}, jaegerTables...)...), []map[string]interface{}{
+		{"peer": net.ParseIP("10.0.0.2"), "schema_version": schemaVersion1},
+		// The joining node without the schema version is skipped
+		{"peer": net.ParseIP("10.0.0.3"), "schema_version": nil},
+	})
+	checker := newSchemaChecker(t, session)
+	if res := checker.Check(context.Background()); !res.Healthy {
+		t.Fatalf("expected healthy result, got %v", res.Err)
+	}
+	if checker.schemaVersion != "v004" {
+		t.Errorf("expected schema v004, got %s", checker.schemaVersion)
+	}
+}
+
+func TestVerifyJaegerSchema_MissingTables(t *testing.T) {
+	session := newSchemaSession(tableRows("traces", "service_names", "operation_names_v2", "service_operation_index", "duration_index", "tag_index", "dependencies_v2"), nil)
+	err := newSchemaChecker(t, session).verifyJaegerSchema(context.Background(), session)
+	if err == nil || err.Error() != "keyspace 'jaeger' with Jaeger schema v003 is missing tables: sampling_probabilities" {
+		t.Fatalf("expected missing tables error, got %v", err)
+	}
+
+	session = newSchemaSession(nil, nil)
+	err = newSchemaChecker(t, session).verifyJaegerSchema(context.Background(), session)
+	if err == nil || err.Error() != "keyspace 'jaeger' with Jaeger schema unknown is missing tables: "+strings.Join(jaegerTables, ", ") {
+		t.Fatalf("expected all tables missing, got %v", err)
+	}
+}
+
+func TestVerifyJaegerSchema_Disagreement(t *testing.T) {
+	session := newSchemaSession(tableRows(jaegerTables...), []map[string]interface{}{
+		{"peer": net.ParseIP("10.0.0.2"), "schema_version": schemaVersion1},
+		{"peer": net.ParseIP("10.0.0.3"), "schema_version": schemaVersion2},
+	})
+	err := newSchemaChecker(t, session).verifyJaegerSchema(context.Background(), session)
+	if err == nil ||
+		!strings.Contains(err.Error(), schemaVersion1.String()+" on local, 10.0.0.2") ||
+		!strings.Contains(err.Error(), schemaVersion2.String()+" on 10.0.0.3") {
+		t.Fatalf("expected disagreement error, got %v", err)
+	}
+}
+
+func TestVerifyJaegerSchema_QueryFailure(t *testing.T) {
+	session := &mockCassandraSession{errs: map[string]error{"SELECT table_name": errors.New("unauthorized")}}
+	err := newSchemaChecker(t, session).verifyJaegerSchema(context.Background(), session)
+	if err == nil || err.Error() != "can't read tables of keyspace 'jaeger': unauthorized" {
+		t.Fatalf("expected query error, got %v", err)
+	}
+}
+
+func TestDetectSchemaVersion(t *testing.T) {
+	tests := []struct {
+		tables []string
+		want   string
+	}{
+		{[]string{"traces", "dependencies"}, "v001"},
+		{[]string{"traces", "dependencies_v2"}, "v002"},
+		{[]string{"traces", "dependencies_v2", "operation_names_v2"}, "v003"},
+		{jaegerTables, "v004"},
+		{nil, "unknown"},
+	}
+	for _, tt := range tests {
+		tables := map[string]bool{}
+		for _, table := range tt.tables {
+			tables[table] = true
+		}
+		if got := detectSchemaVersion(tables); got != tt.want {
+			t.Errorf("detectSchemaVersion(%v) = %s, want %s", tt.tables, got, tt.want)
+		}
+	}
+}
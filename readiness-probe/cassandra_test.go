@@ -0,0 +1,318 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// newTestCassandraChecker creates the checker with the flag defaults changed by the given flags and the mock session
+func newTestCassandraChecker(t *testing.T, session CassandraSession, args ...string) *cassandraChecker {
+	t.Helper()
+	checker, _, err := configureCassandraChecker(newTestConfig(t, args...))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	checker.session = session
+	return checker
+}
+
+func TestCassandraHealth_NilSession(t *testing.T) {
+	checker := &cassandraChecker{
+		session:     nil,
+		errorsCount: 1,
+		keyspace:    "test",
+		testTable:   "test",
+	}
+	if checker.Check(context.Background()).Healthy {
+		t.Error("expected false for nil cassandra session")
+	}
+}
+
+// Mock implementations for testing
+type mockCassandraSession struct {
+	queryResult error
+	// errs contains the errors of the statements by the statement prefix, for example INSERT
+	errs       map[string]error
+	statements []string
+	// row is the value of the last INSERT, it is returned by Scan
+	row interface{}
+	// rows contains the rows of the statements by the statement prefix
+	rows map[string][]map[string]interface{}
+	// closed is set when the session is closed
+	closed bool
+}
+
+func (m *mockCassandraSession) Query(stmt string, values ...interface{}) Query {
+	m.statements = append(m.statements, stmt)
+	result := m.queryResult
+	for prefix, err := range m.errs {
+		if strings.HasPrefix(stmt, prefix) {
+			result = err
+		}
+	}
+	if result == nil && strings.HasPrefix(stmt, "INSERT") && len(values) > 1 {
+		m.row = values[1]
+	}
+	var rows []map[string]interface{}
+	for prefix, r := range m.rows {
+		if strings.HasPrefix(stmt, prefix) {
+			rows = r
+		}
+	}
+	return &mockQuery{result: result, session: m, rows: rows}
+}
+
+func (m *mockCassandraSession) Close() {
+	m.closed = true
+}
+
+type mockQuery struct {
+	result  error
+	session *mockCassandraSession
+	rows    []map[string]interface{}
+	ctx     context.Context
+}
+
+func (m *mockQuery) WithContext(ctx context.Context) Query {
+	m.ctx = ctx
+	return m
+}
+
+// err returns the error of the cancelled context like the driver or the configured result
+func (m *mockQuery) err() error {
+	if m.ctx != nil && m.ctx.Err() != nil {
+		return m.ctx.Err()
+	}
+	return m.result
+}
+
+func (m *mockQuery) Rows() ([]map[string]interface{}, error) {
+	return m.rows, m.err()
+}
+
+func (m *mockQuery) Exec() error {
+	return m.err()
+}
+
+func (m *mockQuery) Scan(dest ...interface{}) error {
+	if err := m.err(); err != nil {
+		return err
+	}
+	if m.session.row == nil {
+		return gocql.ErrNotFound
+	}
+	if value, ok := m.session.row.(gocql.UUID); ok && len(dest) > 0 {
+		*dest[0].(*gocql.UUID) = value
+	}
+	return nil
+}
+
+func TestCassandraHealth_Success(t *testing.T) {
+	checker := &cassandraChecker{
+		session:     &mockCassandraSession{queryResult: nil}, // Mock successful query
+		errorsCount: 3,
+		keyspace:    "test",
+		testTable:   "test",
+	}
+	if !checker.Check(context.Background()).Healthy {
+		t.Error("expected true for successful cassandra query")
+	}
+}
+
+func TestCassandraHealth_QueryFailure(t *testing.T) {
+	checker := &cassandraChecker{
+		session:     &mockCassandraSession{queryResult: fmt.Errorf("query failed")}, // Mock failed query
+		errorsCount: 1,
+		keyspace:    "test",
+		testTable:   "test",
+	}
+	if checker.Check(context.Background()).Healthy {
+		t.Error("expected false for failed cassandra query")
+	}
+}
+
+func TestCassandraHealth_CancelledBetweenAttempts(t *testing.T) {
+	checker := &cassandraChecker{
+		session:     &mockCassandraSession{queryResult: fmt.Errorf("query failed")},
+		errorsCount: 3,
+		retry:       backoff{base: time.Hour},
+		keyspace:    "test",
+		testTable:   "test",
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	res := checker.Check(ctx)
+	if elapsed := time.Since(start); elapsed > 4*time.Second {
+		t.Fatalf("expected the sleep to be interrupted, took %s", elapsed)
+	}
+	if res.Healthy || res.Attempts != 1 || !errors.Is(res.Err, context.Canceled) || !strings.Contains(res.Err.Error(), "query failed") {
+		t.Fatalf("unexpected result %+v", res)
+	}
+}
+
+func TestCassandraHealth_QueriesUseContext(t *testing.T) {
+	checker := &cassandraChecker{
+		session:     &mockCassandraSession{},
+		errorsCount: 1,
+		keyspace:    "test",
+		testTable:   "test",
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if res := checker.Check(ctx); res.Healthy || !errors.Is(res.Err, context.Canceled) {
+		t.Fatalf("expected the query to be cancelled, got %+v", res)
+	}
+}
+
+func TestCreateSessionWithRetry_Failure(t *testing.T) {
+	cluster := &gocql.ClusterConfig{Hosts: []string{"127.0.0.1"}, ConnectTimeout: 1 * time.Millisecond}
+	_, err := createSessionWithRetry(cluster, 1, 1*time.Millisecond)
+	if err == nil {
+		t.Fatal("expected error when creating session fails")
+	}
+	if !strings.Contains(err.Error(), "failed to create Cassandra session after 1 attempts") {
+		t.Fatalf("unexpected error message: %v", err)
+	}
+}
+
+func TestCreateCassandraClient_ExitOnSessionFailure(t *testing.T) {
+	runExitTest(t, "BE_CRASHER_CREATE_CASS", "TestCreateCassandraClient_ExitOnSessionFailure", func() {
+		// this should call os.Exit(1) on failure
+		createCassandraClient([]string{"127.0.0.1"}, 0, "", "", 1*time.Second, 1, "dc", "ks", testCassandraOptions)
+	})
+}
+
+func TestCreateCassandraClient_TLS_InsecureSkipVerify(t *testing.T) {
+	// This should test the insecureSkipVerify path in createCassandraClient
+	runExitTest(t, "BE_CRASHER_CASS_TLS", "TestCreateCassandraClient_TLS_InsecureSkipVerify", func() {
+		opts := testCassandraOptions
+		opts.tlsConfig, _ = createTLSConfig(tlsOptions{insecureSkipVerify: true})
+		createCassandraClient([]string{"127.0.0.1"}, 9042, "u", "p", 1*time.Second, 1, "dc", "ks", opts)
+	})
+}
+
+func TestCreateCassandraClient_TLS_WithCerts(t *testing.T) {
+	// This should test the TLS with certs path in createCassandraClient
+	runExitTest(t, "BE_CRASHER_CASS_TLS_CERTS", "TestCreateCassandraClient_TLS_WithCerts", func() {
+		crt, key := generateSelfSignedCert(t)
+		caFile, err := os.CreateTemp(t.TempDir(), "ca-*.pem")
+		if err != nil {
+			t.Fatalf("create temp ca: %v", err)
+		}
+		certBytes, err := os.ReadFile(crt)
+		if err != nil {
+			t.Fatalf("read crt: %v", err)
+		}
+		if _, err := caFile.Write(certBytes); err != nil {
+			t.Fatalf("write ca: %v", err)
+		}
+		if err := caFile.Close(); err != nil {
+			t.Fatalf("failed to close ca file: %v", err)
+		}
+
+		opts := testCassandraOptions
+		opts.tlsConfig, _ = createTLSConfig(tlsOptions{caPath: caFile.Name(), crtPath: crt, keyPath: key})
+		createCassandraClient([]string{"127.0.0.1"}, 9042, "u", "p", 1*time.Second, 1, "dc", "ks", opts)
+	})
+}
+
+func TestCassandraHealth_RetrySuccess(t *testing.T) {
+	// Since we can't easily mock dynamic behavior, we'll test with a successful mock
+	checker := &cassandraChecker{
+		session:     &mockCassandraSession{queryResult: nil}, // Always succeed
+		errorsCount: 3,
+		keyspace:    "test",
+		testTable:   "test",
+	}
+	if !checker.Check(context.Background()).Healthy {
+		t.Error("expected true for successful cassandra query")
+	}
+}
+
+func TestCassandraHealth_MaxErrors(t *testing.T) {
+	checker := &cassandraChecker{
+		session:     &mockCassandraSession{queryResult: fmt.Errorf("persistent failure")},
+		errorsCount: 2,
+		keyspace:    "test",
+		testTable:   "test",
+	}
+	if checker.Check(context.Background()).Healthy {
+		t.Error("expected false when max errors reached")
+	}
+}
+
+var testCassandraOptions = cassandraClusterOptions{consistency: gocql.Quorum, protoVersion: 4, numConns: 1}
+
+func TestParseCassandraOptions(t *testing.T) {
+	cfg := &Config{consistency: "local_quorum", protoVersion: 5, numConns: 2, initialHostLookup: true, hostSelectionPolicy: "tokenAware"}
+	opts, err := parseCassandraOptions(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := cassandraClusterOptions{consistency: gocql.LocalQuorum, protoVersion: 5, numConns: 2, initialHostLookup: true, tokenAware: true}
+	if opts != want {
+		t.Fatalf("expected %+v, got %+v", want, opts)
+	}
+}
+
+func TestParseCassandraOptions_Invalid(t *testing.T) {
+	valid := Config{consistency: "QUORUM", protoVersion: 4, numConns: 1, hostSelectionPolicy: dcAwarePolicy}
+	tests := []struct {
+		modify func(c *Config)
+		want   string
+	}{
+		{func(c *Config) { c.consistency = "MOST" }, "invalid -consistency 'MOST'"},
+		{func(c *Config) { c.protoVersion = 2 }, "invalid -protoVersion 2"},
+		{func(c *Config) { c.numConns = 0 }, "invalid -numConns 0"},
+		{func(c *Config) { c.hostSelectionPolicy = "random" }, "invalid -hostSelectionPolicy 'random'"},
+		{func(c *Config) { c.hostSelectionPolicy = tokenAwarePolicy }, "requires -initialHostLookup=true"},
+	}
+	for _, tt := range tests {
+		cfg := valid
+		tt.modify(&cfg)
+		if _, err := parseCassandraOptions(&cfg); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("expected error '%s', got %v", tt.want, err)
+		}
+	}
+}
+
+func TestNewCassandraCluster_Options(t *testing.T) {
+	opts := cassandraClusterOptions{consistency: gocql.LocalOne, protoVersion: 5, numConns: 3, initialHostLookup: true, tokenAware: true}
+	cluster := newCassandraCluster([]string{"127.0.0.1"}, 9042, "u", "p", 1, "dc1", "ks", opts)
+	if cluster.Consistency != gocql.LocalOne || cluster.ProtoVersion != 5 || cluster.NumConns != 3 || cluster.DisableInitialHostLookup {
+		t.Fatalf("unexpected cluster config: %+v", cluster)
+	}
+	if fmt.Sprintf("%T", cluster.PoolConfig.HostSelectionPolicy) != "*gocql.tokenAwareHostPolicy" {
+		t.Fatalf("expected token aware policy, got %T", cluster.PoolConfig.HostSelectionPolicy)
+	}
+
+	cluster = newCassandraCluster([]string{"127.0.0.1"}, 9042, "u", "p", 1, "dc1", "ks", testCassandraOptions)
+	if !cluster.DisableInitialHostLookup || fmt.Sprintf("%T", cluster.PoolConfig.HostSelectionPolicy) != "*gocql.dcAwareRR" {
+		t.Fatalf("expected DC aware policy without host lookup, got %T", cluster.PoolConfig.HostSelectionPolicy)
+	}
+}
+
+func TestNewCassandraCluster_ContactPoints(t *testing.T) {
+	cluster := newCassandraCluster([]string{"cassandra-0", "cassandra-1", "cassandra-2"}, 9042, "u", "p", 1, "dc1", "ks", testCassandraOptions)
+	if strings.Join(cluster.Hosts, ",") != "cassandra-0,cassandra-1,cassandra-2" {
+		t.Fatalf("expected all contact points, got %v", cluster.Hosts)
+	}
+}
+
+func TestNewCassandraCluster_ReloadedTLS(t *testing.T) {
+	opts := testCassandraOptions
+	opts.tlsConfig = newCertReloader("", "", "").tlsConfig(tlsOptions{})
+	cluster := newCassandraCluster([]string{"cassandra-0"}, 9042, "u", "p", 1, "dc1", "ks", opts)
+	dialer, ok := cluster.HostDialer.(*tlsHostDialer)
+	if !ok || dialer.tlsConfig != opts.tlsConfig || cluster.SslOpts != nil {
+		t.Fatalf("expected the host dialer with the reloaded TLS configuration, got %T", cluster.HostDialer)
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Actions when the live replicas are below the minimum
+const (
+	topologyFail    string = "fail"
+	topologyDegrade string = "degrade"
+)
+
+// Cassandra replication strategies
+const (
+	simpleStrategy          string = "SimpleStrategy"
+	networkTopologyStrategy string = "NetworkTopologyStrategy"
+)
+
+// nodeState describes one Cassandra node from system.local or system.peers
+type nodeState struct {
+	Address    string `json:"address"`
+	Datacenter string `json:"datacenter"`
+	Rack       string `json:"rack,omitempty"`
+	HostID     string `json:"hostId,omitempty"`
+	Up         bool   `json:"up"`
+}
+
+// datacenterState describes the replicas of the keyspace in one datacenter
+type datacenterState struct {
+	Name              string `json:"name"`
+	Nodes             int    `json:"nodes"`
+	LiveNodes         int    `json:"liveNodes"`
+	ReplicationFactor int    `json:"replicationFactor"`
+	LiveReplicas      int    `json:"liveReplicas"`
+	MinLiveReplicas   int    `json:"minLiveReplicas"`
+}
+
+// topologyState is the Cassandra topology in the health report
+type topologyState struct {
+	Datacenters []datacenterState `json:"datacenters"`
+	Nodes       []nodeState       `json:"nodes"`
+}
+
+// dialFunc opens the connection to check that the node is up
+type dialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// readNodes returns the coordinator node from system.local and its peers from system.peers
+func (c *cassandraChecker) readNodes(ctx context.Context, session CassandraSession) ([]nodeState, error) {
+	local, err := session.Query("SELECT data_center, rack, host_id, rpc_address FROM system.local WHERE key = 'local';").WithContext(ctx).Rows()
+	if err != nil {
+		return nil, fmt.Errorf("can't read the node from system.local: %w", err)
+	}
+	peers, err := session.Query("SELECT peer, data_center, rack, host_id, rpc_address FROM system.peers;").WithContext(ctx).Rows()
+	if err != nil {
+		return nil, fmt.Errorf("can't read the nodes from system.peers: %w", err)
+	}
+	var nodes []nodeState
+	for _, row := range local {
+		nodes = append(nodes, nodeState{
+			Address:    rowString(row, "rpc_address"),
+			Datacenter: rowString(row, "data_center"),
+			Rack:       rowString(row, "rack"),
+			HostID:     rowString(row, "host_id"),
+			// The coordinator has just answered the query
+			Up: true,
+		})
+	}
+	for _, row := range peers {
+		address := rowString(row, "rpc_address")
+		// The nodes listening on all interfaces report 0.0.0.0 as the rpc address
+		if address == "" || address == "0.0.0.0" || address == "::" {
+			address = rowString(row, "peer")
+		}
+		nodes = append(nodes, nodeState{
+			Address:    address,
+			Datacenter: rowString(row, "data_center"),
+			Rack:       rowString(row, "rack"),
+			HostID:     rowString(row, "host_id"),
+		})
+	}
+	return nodes, nil
+}
+
+// readReplication returns the replication factor of the keyspace by datacenter,
+// the empty datacenter name means the SimpleStrategy replication over the whole cluster
+func (c *cassandraChecker) readReplication(ctx context.Context, session CassandraSession) (map[string]int, error) {
+	rows, err := session.Query("SELECT replication FROM system_schema.keyspaces WHERE keyspace_name = ?;", c.keyspace).WithContext(ctx).Rows()
+	if err != nil {
+		return nil, fmt.Errorf("can't read replication of keyspace '%s': %w", c.keyspace, err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("keyspace '%s' is missing", c.keyspace)
+	}
+	replication, _ := rows[0]["replication"].(map[string]string)
+	class := replication["class"]
+	factors := map[string]int{}
+	for key, value := range replication {
+		if key == "class" {
+			continue
+		}
+		if strings.HasSuffix(class, simpleStrategy) {
+			if key != "replication_factor" {
+				continue
+			}
+			key = ""
+		}
+		factor, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid replication factor '%s' of keyspace '%s' in '%s'", value, c.keyspace, key)
+		}
+		factors[key] = factor
+	}
+	if !strings.HasSuffix(class, simpleStrategy) && !strings.HasSuffix(class, networkTopologyStrategy) {
+		return nil, fmt.Errorf("unsupported replication class '%s' of keyspace '%s'", class, c.keyspace)
+	}
+	return factors, nil
+}
+
+// probeNodes marks the peers as up when their native transport port accepts the connection
+func (c *cassandraChecker) probeNodes(ctx context.Context, nodes []nodeState) {
+	dial := c.dial
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	done := make(chan struct{}, len(nodes))
+	for i := range nodes {
+		if nodes[i].Up {
+			done <- struct{}{}
+			continue
+		}
+		go func() {
+			defer func() { done <- struct{}{} }()
+			dialCtx, cancel := context.WithTimeout(ctx, c.nodeTimeout)
+			defer cancel()
+			conn, err := dial(dialCtx, "tcp", net.JoinHostPort(nodes[i].Address, strconv.Itoa(c.nativePort)))
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+			nodes[i].Up = true
+		}()
+	}
+	for range nodes {
+		<-done
+	}
+}
+
+// checkTopology checks that the live replicas of the keyspace in each datacenter are not below the minimum.
+// In the worst case all down nodes hold the replicas of the same token range,
+// so the live replicas are the replication factor minus the down nodes of the datacenter.
+func (c *cassandraChecker) checkTopology(ctx context.Context, session CassandraSession) (*topologyState, error) {
+	nodes, err := c.readNodes(ctx, session)
+	if err != nil {
+		return nil, err
+	}
+	factors, err := c.readReplication(ctx, session)
+	if err != nil {
+		return nil, err
+	}
+	c.probeNodes(ctx, nodes)
+
+	state := &topologyState{Nodes: nodes}
+	byDatacenter := map[string]*datacenterState{}
+	for name, factor := range factors {
+		byDatacenter[name] = &datacenterState{Name: name, ReplicationFactor: factor}
+	}
+	for _, node := range nodes {
+		name := node.Datacenter
+		if _, ok := factors[""]; ok {
+			name = ""
+		}
+		dc, ok := byDatacenter[name]
+		if !ok {
+			// The datacenter without the replicas of the keyspace
+			dc = &datacenterState{Name: name}
+			byDatacenter[name] = dc
+		}
+		dc.Nodes += 1
+		if node.Up {
+			dc.LiveNodes += 1
+		}
+	}
+
+	var problems []string
+	for _, dc := range byDatacenter {
+		if dc.Name == "" {
+			dc.Name = "all datacenters"
+		}
+		replicas := min(dc.ReplicationFactor, dc.Nodes)
+		dc.LiveReplicas = max(replicas-(dc.Nodes-dc.LiveNodes), 0)
+		dc.MinLiveReplicas = c.minLiveReplicas
+		if dc.MinLiveReplicas <= 0 {
+			// QUORUM of the replicas in the datacenter
+			dc.MinLiveReplicas = dc.ReplicationFactor/2 + 1
+		}
+		if dc.ReplicationFactor == 0 {
+			dc.MinLiveReplicas = 0
+		}
+		state.Datacenters = append(state.Datacenters, *dc)
+		if dc.LiveReplicas < dc.MinLiveReplicas {
+			problems = append(problems, fmt.Sprintf("%s has %d of %d live replicas, required %d (%d of %d nodes are up)",
+				dc.Name, dc.LiveReplicas, dc.ReplicationFactor, dc.MinLiveReplicas, dc.LiveNodes, dc.Nodes))
+		}
+	}
+	sort.Slice(state.Datacenters, func(i, j int) bool { return state.Datacenters[i].Name < state.Datacenters[j].Name })
+	sort.Slice(state.Nodes, func(i, j int) bool {
+		if state.Nodes[i].Datacenter != state.Nodes[j].Datacenter {
+			return state.Nodes[i].Datacenter < state.Nodes[j].Datacenter
+		}
+		return state.Nodes[i].Address < state.Nodes[j].Address
+	})
+	sort.Strings(problems)
+	if len(problems) > 0 {
+		return state, fmt.Errorf("keyspace '%s' has not enough live replicas: %s", c.keyspace, strings.Join(problems, "; "))
+	}
+	return state, nil
+}
+
+// rowString returns the column value of the row as string, the missing and null values are empty
+func rowString(row map[string]interface{}, column string) string {
+	value, ok := row[column]
+	if !ok || value == nil {
+		return ""
+	}
+	if ip, ok := value.(net.IP); ok && ip == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
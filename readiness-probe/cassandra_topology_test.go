@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+// dialUp returns the dial function which connects only to the given addresses
+func dialUp(addresses ...string) dialFunc {
+	return func(_ context.Context, _, address string) (net.Conn, error) {
+		for _, up := range addresses {
+			if address == net.JoinHostPort(up, "9042") {
+				client, server := net.Pipe()
+				_ = server.Close()
+				return client, nil
+			}
+		}
+		return nil, errors.New("connection refused")
+	}
+}
+
+func newTopologyChecker(t *testing.T, replication map[string]string, dial dialFunc, args ...string) *cassandraChecker {
+	session := &mockCassandraSession{rows: map[string][]map[string]interface{}{
+		"SELECT data_center, rack, host_id, rpc_address FROM system.local": {
+			{"data_center": "dc1", "rack": "rack1", "rpc_address": net.ParseIP("10.0.1.1")},
+		},
+		"SELECT peer, data_center, rack, host_id, rpc_address FROM system.peers": {
+			{"peer": net.ParseIP("10.0.1.2"), "data_center": "dc1", "rack": "rack1", "rpc_address": net.ParseIP("10.0.1.2")},
+			{"peer": net.ParseIP("10.0.1.3"), "data_center": "dc1", "rack": "rack1", "rpc_address": net.ParseIP("0.0.0.0")},
+			{"peer": net.ParseIP("10.0.2.1"), "data_center": "dc2", "rack": "rack1", "rpc_address": net.ParseIP("10.0.2.1")},
+			{"peer": net.ParseIP("10.0.2.2"), "data_center": "dc2", "rack": "rack1", "rpc_address": net.ParseIP("10.0.2.2")},
+		},
+		"SELECT replication FROM system_schema.keyspaces": {{"replication": replication}},
+	}}
+	checker := newTestCassandraChecker(t, session, append([]string{"-errors=1", "-verifyTopology=true", "-timeout=1"}, args...)...)
+	checker.dial = dial
+	return checker
+}
+
+var networkTopology = map[string]string{"class": "org.apache.cassandra.locator.NetworkTopologyStrategy", "dc1": "3", "dc2": "2"}
+
+func TestCheckTopology_AllUp(t *testing.T) {
+	checker := newTopologyChecker(t, networkTopology, dialUp("10.0.1.2", "10.0.1.3", "10.0.2.1", "10.0.2.2"))
+	res := checker.Check(context.Background())
+	if !res.Healthy || res.Degraded {
+		t.Fatalf("expected healthy result, got %v", res.Err)
+	}
+	topology := res.Details["topology"].(*topologyState)
+	want := []datacenterState{
+		{Name: "dc1", Nodes: 3, LiveNodes: 3, ReplicationFactor: 3, LiveReplicas: 3, MinLiveReplicas: 2},
+		{Name: "dc2", Nodes: 2, LiveNodes: 2, ReplicationFactor: 2, LiveReplicas: 2, MinLiveReplicas: 2},
+	}
+	if len(topology.Datacenters) != 2 || topology.Datacenters[0] != want[0] || topology.Datacenters[1] != want[1] {
+		t.Fatalf("unexpected datacenters: %+v", topology.Datacenters)
+	}
+	if len(topology.Nodes) != 5 || topology.Nodes[2].Address != "10.0.1.3" || !topology.Nodes[2].Up {
+		t.Fatalf("unexpected nodes: %+v", topology.Nodes)
+	}
+}
+
+func TestCheckTopology_NotEnoughReplicas(t *testing.T) {
+	checker := newTopologyChecker(t, networkTopology, dialUp("10.0.1.2", "10.0.2.1"))
+	res := checker.Check(context.Background())
+	if res.Healthy {
+		t.Fatal("expected failed result")
+	}
+	if res.Err.Error() != "keyspace 'jaeger' has not enough live replicas: dc2 has 1 of 2 live replicas, required 2 (1 of 2 nodes are up)" {
+		t.Fatalf("unexpected error: %v", res.Err)
+	}
+	if _, ok := res.Details["topology"]; !ok {
+		t.Error("expected topology in failed result")
+	}
+}
+
+func TestCheckTopology_Degrade(t *testing.T) {
+	checker := newTopologyChecker(t, networkTopology, dialUp(), "-topologyPolicy=degrade", "-minLiveReplicas=1")
+	res := checker.Check(context.Background())
+	if !res.Healthy || !res.Degraded || !strings.Contains(res.Err.Error(), "dc2 has 0 of 2 live replicas, required 1") {
+		t.Fatalf("expected degraded result, got %+v", res)
+	}
+	if strings.Contains(res.Err.Error(), "dc1 has") {
+		t.Fatalf("dc1 has one live replica, got %v", res.Err)
+	}
+}
+
+func TestCheckTopology_SimpleStrategy(t *testing.T) {
+	checker := newTopologyChecker(t, map[string]string{"class": "org.apache.cassandra.locator.SimpleStrategy", "replication_factor": "3"}, dialUp("10.0.1.2"))
+	topology, err := checker.checkTopology(context.Background(), checker.session)
+	if err == nil || err.Error() != "keyspace 'jaeger' has not enough live replicas: all datacenters has 0 of 3 live replicas, required 2 (2 of 5 nodes are up)" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(topology.Datacenters) != 1 {
+		t.Fatalf("expected one group for SimpleStrategy, got %+v", topology.Datacenters)
+	}
+}
+
+func TestCheckTopology_QueryFailureIsNotDegraded(t *testing.T) {
+	checker := newTopologyChecker(t, networkTopology, dialUp(), "-topologyPolicy=degrade")
+	checker.session.(*mockCassandraSession).errs = map[string]error{"SELECT peer": errors.New("unauthorized")}
+	res := checker.Check(context.Background())
+	if res.Healthy || res.Err.Error() != "can't read the nodes from system.peers: unauthorized" {
+		t.Fatalf("expected failed result, got %+v", res)
+	}
+}
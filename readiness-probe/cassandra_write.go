@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// createWriteCheckTable creates the probe table if it is missing, the rows expire by the table TTL
+// even if the probe is stopped between the write and the read. The existing table is looked up first,
+// because CREATE TABLE IF NOT EXISTS requires the CREATE permission even for the existing table.
+func (c *cassandraChecker) createWriteCheckTable(ctx context.Context, session CassandraSession) error {
+	rows, err := session.Query("SELECT table_name FROM system_schema.tables WHERE keyspace_name = ? AND table_name = ?;",
+		c.keyspace, c.writeCheckTable).WithContext(ctx).Rows()
+	if err != nil {
+		return fmt.Errorf("can't look up write check table '%s.%s': %w", c.keyspace, c.writeCheckTable, err)
+	}
+	if len(rows) > 0 {
+		return nil
+	}
+	stmt := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s.%s (probe text PRIMARY KEY, value timeuuid) WITH default_time_to_live = %d;",
+		c.keyspace, c.writeCheckTable, int(c.writeCheckTTL.Seconds()))
+	if err := session.Query(stmt).WithContext(ctx).Exec(); err != nil {
+		return fmt.Errorf("can't create write check table '%s.%s': %w", c.keyspace, c.writeCheckTable, err)
+	}
+	slog.Info(fmt.Sprintf("Write check table '%s.%s' is created", c.keyspace, c.writeCheckTable))
+	return nil
+}
+
+// writeCanary inserts the canary row with TTL and reads it back at the consistency of the session,
+// the latency of the write and the read is returned separately
+func (c *cassandraChecker) writeCanary(ctx context.Context, session CassandraSession) (map[string]time.Duration, error) {
+	if session == nil {
+		return nil, fmt.Errorf("cassandra session is not initialized")
+	}
+	if c.createTable && !c.tableCreated {
+		if err := c.createWriteCheckTable(ctx, session); err != nil {
+			return nil, err
+		}
+		c.tableCreated = true
+	}
+	steps := map[string]time.Duration{}
+	hostname, _ := os.Hostname()
+	value := gocql.TimeUUID()
+
+	start := time.Now()
+	insert := fmt.Sprintf("INSERT INTO %s.%s (probe, value) VALUES (?, ?) USING TTL %d;", c.keyspace, c.writeCheckTable, int(c.writeCheckTTL.Seconds()))
+	if err := session.Query(insert, hostname, value).WithContext(ctx).Exec(); err != nil {
+		return steps, fmt.Errorf("write check can't insert the canary row into '%s.%s': %w", c.keyspace, c.writeCheckTable, err)
+	}
+	steps["write"] = time.Since(start)
+
+	start = time.Now()
+	var read gocql.UUID
+	sel := fmt.Sprintf("SELECT value FROM %s.%s WHERE probe = ?;", c.keyspace, c.writeCheckTable)
+	err := session.Query(sel, hostname).WithContext(ctx).Scan(&read)
+	if errors.Is(err, gocql.ErrNotFound) {
+		return steps, fmt.Errorf("write check can't find the canary row in '%s.%s'", c.keyspace, c.writeCheckTable)
+	}
+	if err != nil {
+		return steps, fmt.Errorf("write check can't read the canary row from '%s.%s': %w", c.keyspace, c.writeCheckTable, err)
+	}
+	steps["read"] = time.Since(start)
+	if read != value {
+		return steps, fmt.Errorf("write check read the stale canary row from '%s.%s': %s, expected %s", c.keyspace, c.writeCheckTable, read, value)
+	}
+	slog.Info(fmt.Sprintf("Write check of '%s.%s' took %s for the write and %s for the read", c.keyspace, c.writeCheckTable, steps["write"], steps["read"]))
+	return steps, nil
+}
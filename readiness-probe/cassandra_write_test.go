@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gocql/gocql"
+)
+
+func newWriteCassandraChecker(t *testing.T, session *mockCassandraSession, args ...string) *cassandraChecker {
+	return newTestCassandraChecker(t, session, append([]string{"-errors=1", "-writeCheck=true"}, args...)...)
+}
+
+func TestCassandraWriteCanary_Success(t *testing.T) {
+	session := &mockCassandraSession{}
+	checker := newWriteCassandraChecker(t, session)
+	res := checker.Check(context.Background())
+	if !res.Healthy {
+		t.Fatalf("expected healthy result, got %v", res.Err)
+	}
+	if _, ok := res.Steps["write"]; !ok {
+		t.Error("expected write latency in result")
+	}
+	if _, ok := res.Steps["read"]; !ok {
+		t.Error("expected read latency in result")
+	}
+	if len(session.statements) != 5 ||
+		!strings.HasPrefix(session.statements[1], "SELECT table_name FROM system_schema.tables") ||
+		!strings.HasPrefix(session.statements[2], "CREATE TABLE IF NOT EXISTS jaeger.readiness_probe") ||
+		!strings.Contains(session.statements[3], "USING TTL 60") {
+		t.Fatalf("unexpected statements: %v", session.statements)
+	}
+
+	// The table is created only once
+	session.statements = nil
+	if res := checker.Check(context.Background()); !res.Healthy {
+		t.Fatalf("expected healthy result, got %v", res.Err)
+	}
+	for _, stmt := range session.statements {
+		if strings.HasPrefix(stmt, "CREATE") {
+			t.Fatalf("unexpected second table creation: %v", session.statements)
+		}
+	}
+}
+
+func TestCassandraWriteCanary_WithoutCreate(t *testing.T) {
+	session := &mockCassandraSession{}
+	checker := newWriteCassandraChecker(t, session, "-createWriteCheckTable=false")
+	if res := checker.Check(context.Background()); !res.Healthy {
+		t.Fatalf("expected healthy result, got %v", res.Err)
+	}
+	for _, stmt := range session.statements {
+		if strings.HasPrefix(stmt, "CREATE") {
+			t.Fatalf("unexpected table creation: %v", session.statements)
+		}
+	}
+}
+
+func TestCassandraWriteCanary_CreateNotPermitted(t *testing.T) {
+	notPermitted := map[string]error{"CREATE": errors.New("User probe has no CREATE permission on <keyspace jaeger>")}
+	session := &mockCassandraSession{errs: notPermitted}
+	res := newWriteCassandraChecker(t, session).Check(context.Background())
+	if res.Healthy || !strings.Contains(res.Err.Error(), "can't create write check table 'jaeger.readiness_probe'") {
+		t.Fatalf("expected create error for the missing table, got %v", res.Err)
+	}
+
+	// The table created by the administrator is used without the CREATE permission
+	session = &mockCassandraSession{errs: notPermitted, rows: map[string][]map[string]interface{}{
+		"SELECT table_name FROM system_schema.tables": {{"table_name": "readiness_probe"}},
+	}}
+	if res := newWriteCassandraChecker(t, session).Check(context.Background()); !res.Healthy {
+		t.Fatalf("expected healthy result with the existing table, got %v", res.Err)
+	}
+	for _, stmt := range session.statements {
+		if strings.HasPrefix(stmt, "CREATE") {
+			t.Fatalf("unexpected table creation: %v", session.statements)
+		}
+	}
+}
+
+func TestCassandraWriteCanary_WriteFailure(t *testing.T) {
+	session := &mockCassandraSession{errs: map[string]error{"INSERT": &gocql.RequestErrWriteTimeout{}}}
+	res := newWriteCassandraChecker(t, session).Check(context.Background())
+	if res.Healthy || !strings.Contains(res.Err.Error(), "can't insert the canary row") {
+		t.Fatalf("expected write error, got %v", res.Err)
+	}
+	if _, ok := res.Steps["read"]; ok {
+		t.Error("unexpected read latency after failed write")
+	}
+}
+
+func TestCassandraWriteCanary_NotFound(t *testing.T) {
+	session := &mockCassandraSession{errs: map[string]error{"SELECT value": gocql.ErrNotFound}}
+	steps, err := newWriteCassandraChecker(t, session).writeCanary(context.Background(), session)
+	if err == nil || err.Error() != "write check can't find the canary row in 'jaeger.readiness_probe'" {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+	if _, ok := steps["write"]; !ok {
+		t.Error("expected write latency after successful write")
+	}
+}
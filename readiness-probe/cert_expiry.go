@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"time"
+)
+
+// Actions when the certificate is about to expire or has expired
+const (
+	certExpiryIgnore  string = "ignore"
+	certExpiryDegrade string = "degrade"
+	certExpiryFail    string = "fail"
+)
+
+// Kinds of the monitored certificates
+const (
+	clientCertificate string = "client"
+	caCertificate     string = "ca"
+	serverCertificate string = "server"
+)
+
+// certificateExpiry describes the expiry of the certificate in the health report
+type certificateExpiry struct {
+	Kind string `json:"kind"`
+	// Name is the server name for the server chain
+	Name         string    `json:"name,omitempty"`
+	Subject      string    `json:"subject"`
+	NotAfter     time.Time `json:"notAfter"`
+	DaysToExpiry int       `json:"daysToExpiry"`
+}
+
+func newCertificateExpiry(kind string, name string, cert *x509.Certificate, now time.Time) certificateExpiry {
+	return certificateExpiry{
+		Kind:         kind,
+		Name:         name,
+		Subject:      cert.Subject.String(),
+		NotAfter:     cert.NotAfter,
+		DaysToExpiry: int(math.Floor(cert.NotAfter.Sub(now).Hours() / 24)),
+	}
+}
+
+func (e certificateExpiry) String() string {
+	if e.Name != "" {
+		return fmt.Sprintf("%s certificate '%s' of '%s'", e.Kind, e.Subject, e.Name)
+	}
+	return fmt.Sprintf("%s certificate '%s'", e.Kind, e.Subject)
+}
+
+// parseCertificates returns the certificates from the PEM bundle, the blocks which can't be parsed are skipped
+func parseCertificates(data []byte) []*x509.Certificate {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return certs
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		if cert, err := x509.ParseCertificate(block.Bytes); err == nil {
+			certs = append(certs, cert)
+		}
+	}
+}
+
+// earliestExpiry returns the certificate which expires first, the chain or the bundle is valid only until then
+func earliestExpiry(certs []*x509.Certificate) *x509.Certificate {
+	var earliest *x509.Certificate
+	for _, cert := range certs {
+		if earliest == nil || cert.NotAfter.Before(earliest.NotAfter) {
+			earliest = cert
+		}
+	}
+	return earliest
+}
+
+// expiries returns the expiry of the client certificate, of the CA bundle and of the server chains seen in the handshakes
+func (r *certReloader) expiries(now time.Time) []certificateExpiry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var result []certificateExpiry
+	if r.cert != nil && r.cert.Leaf != nil {
+		result = append(result, newCertificateExpiry(clientCertificate, "", r.cert.Leaf, now))
+	}
+	if r.caCert != nil {
+		result = append(result, newCertificateExpiry(caCertificate, "", r.caCert, now))
+	}
+	names := make([]string, 0, len(r.servers))
+	for name := range r.servers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if cert := r.servers[name]; cert != nil {
+			result = append(result, newCertificateExpiry(serverCertificate, name, cert, now))
+		}
+	}
+	return result
+}
+
+// certificatesProvider is implemented by the checkers which connect with the certificates from the files
+type certificatesProvider interface {
+	certificates() *certReloader
+}
+
+// certExpiryChecker adds the expiry of the certificates to the result and applies the expiry policy
+type certExpiryChecker struct {
+	HealthChecker
+	certs   *certReloader
+	warning time.Duration
+	policy  string
+	now     func() time.Time
+}
+
+func newCertExpiryChecker(checker HealthChecker, certs *certReloader, cfg *Config) *certExpiryChecker {
+	return &certExpiryChecker{
+		HealthChecker: checker,
+		certs:         certs,
+		warning:       time.Duration(cfg.certExpiryWarningDays) * 24 * time.Hour,
+		policy:        cfg.certExpiryPolicy,
+		now:           time.Now,
+	}
+}
+
+func (c *certExpiryChecker) Check(ctx context.Context) Result {
+	res := c.HealthChecker.Check(ctx)
+	now := c.now()
+	res.Certificates = c.certs.expiries(now)
+	if !res.Healthy || c.policy == certExpiryIgnore {
+		return res
+	}
+	var expired, expiring []error
+	for _, cert := range res.Certificates {
+		if !cert.NotAfter.After(now) {
+			expired = append(expired, fmt.Errorf("the %s has expired on %s", cert, cert.NotAfter.UTC().Format(time.RFC3339)))
+		} else if cert.NotAfter.Sub(now) <= c.warning {
+			expiring = append(expiring, fmt.Errorf("the %s expires in %d days on %s", cert, cert.DaysToExpiry, cert.NotAfter.UTC().Format(time.RFC3339)))
+		}
+	}
+	if len(expired) > 0 && c.policy == certExpiryFail {
+		res.Healthy = false
+		res.Degraded = false
+		res.Err = errors.Join(expired...)
+		return res
+	}
+	if problems := append(expired, expiring...); len(problems) > 0 {
+		err := errors.Join(problems...)
+		slog.Warn(fmt.Sprintf("TLS certificates expire soon: %s", err.Error()))
+		res.Degraded = true
+		res.Err = errors.Join(res.Err, err)
+	}
+	return res
+}
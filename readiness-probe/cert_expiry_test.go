@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCertReloader_Expiries(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	crt, key := generateSelfSignedCert(t)
+	certs := newCertReloader(crt, crt, key)
+	conn, err := tls.Dial("tcp", srv.Listener.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	cs := conn.ConnectionState()
+	cs.ServerName = "127.0.0.1"
+	_ = certs.verifyConnection(cs)
+
+	expiries := certs.expiries(time.Now())
+	if len(expiries) != 3 {
+		t.Fatalf("expected the client, CA and server certificates, got %+v", expiries)
+	}
+	if expiries[0].Kind != clientCertificate || expiries[1].Kind != caCertificate || expiries[0].DaysToExpiry != 0 {
+		t.Errorf("unexpected client and CA expiry: %+v", expiries[:2])
+	}
+	if expiries[2].Kind != serverCertificate || expiries[2].Name != "127.0.0.1" || !expiries[2].NotAfter.Equal(srv.Certificate().NotAfter) {
+		t.Errorf("unexpected server expiry: %+v", expiries[2])
+	}
+}
+
+func TestCertExpiryChecker_Policy(t *testing.T) {
+	crt, key := generateSelfSignedCert(t)
+	certs := newCertReloader(crt, crt, key)
+	tests := []struct {
+		policy   string
+		shift    time.Duration
+		healthy  bool
+		degraded bool
+	}{
+		{certExpiryDegrade, 0, true, true},
+		{certExpiryDegrade, 48 * time.Hour, true, true},
+		{certExpiryFail, 0, true, true},
+		{certExpiryFail, 48 * time.Hour, false, false},
+		{certExpiryIgnore, 48 * time.Hour, true, false},
+	}
+	for _, tt := range tests {
+		checker := newCertExpiryChecker(&stubChecker{result: Result{Healthy: true}}, certs, &Config{certExpiryWarningDays: 30, certExpiryPolicy: tt.policy})
+		checker.now = func() time.Time { return time.Now().Add(tt.shift) }
+		res := checker.Check(context.Background())
+		if res.Healthy != tt.healthy || res.Degraded != tt.degraded {
+			t.Errorf("policy %s after %s: expected healthy %v and degraded %v, got %+v", tt.policy, tt.shift, tt.healthy, tt.degraded, res)
+		}
+		if len(res.Certificates) != 2 {
+			t.Errorf("expected the certificates in the result, got %+v", res.Certificates)
+		}
+	}
+
+	checker := newCertExpiryChecker(&stubChecker{result: Result{Healthy: true}}, certs, &Config{certExpiryWarningDays: 0, certExpiryPolicy: certExpiryDegrade})
+	if res := checker.Check(context.Background()); res.Degraded || res.Err != nil {
+		t.Errorf("expected no problem outside of the warning window, got %v", res.Err)
+	}
+	checker.now = func() time.Time { return time.Now().Add(48 * time.Hour) }
+	if res := checker.Check(context.Background()); !strings.Contains(errorString(res.Err), "has expired") {
+		t.Errorf("expected the expiry error, got %v", res.Err)
+	}
+}
+
+func TestRecordMetrics_CertificateExpiry(t *testing.T) {
+	notAfter := time.Now().Add(10 * 24 * time.Hour)
+	recordMetrics(Result{Name: "main", Backend: opensearch, Healthy: true, Certificates: []certificateExpiry{
+		{Kind: serverCertificate, Name: "opensearch", NotAfter: notAfter},
+	}})
+	days := testutil.ToFloat64(certificateExpiryDays.WithLabelValues("main", opensearch, serverCertificate, "opensearch"))
+	if days < 9.9 || days > 10 {
+		t.Errorf("expected 10 days to expiry, got %f", days)
+	}
+
+	// The series of the certificate which is gone after the reload is deleted
+	recordMetrics(Result{Name: "main", Backend: opensearch, Healthy: true, Certificates: []certificateExpiry{
+		{Kind: clientCertificate, NotAfter: notAfter},
+	}})
+	if days := testutil.ToFloat64(certificateExpiryDays.WithLabelValues("main", opensearch, clientCertificate, "")); days < 9.9 || days > 10 {
+		t.Errorf("expected 10 days to expiry of the client certificate, got %f", days)
+	}
+	if certificateExpiryDays.DeleteLabelValues("main", opensearch, serverCertificate, "opensearch") {
+		t.Error("expected the series of the server certificate to be deleted")
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Result describes the outcome of a single health check of the storage
+type Result struct {
+	Name     string
+	Healthy  bool
+	Backend  string
+	Endpoint string
+	Attempts int
+	Latency  time.Duration
+	Err      error
+	// Steps contains the latency of the separate steps of the check, for example the write and the read of the canary data
+	Steps map[string]time.Duration
+	// Degraded marks the healthy storage with the problem which doesn't fail the readiness, the problem is in Err
+	Degraded bool
+	// Details contains the backend specific state for the health report, for example the Cassandra topology
+	Details map[string]interface{}
+	// Certificates contains the expiry of the TLS certificates used by the check
+	Certificates []certificateExpiry
+	// RetryAfter is the delay requested by the storage with the Retry-After header, the next check doesn't run earlier
+	RetryAfter time.Duration
+	// Checks contains the results of the sub-checks for the composite check
+	Checks []Result
+}
+
+// HealthChecker checks the health of one storage backend.
+// Implementations register themselves with registerChecker in their init function.
+type HealthChecker interface {
+	Check(ctx context.Context) Result
+	Close()
+}
+
+// CheckerFactory creates a HealthChecker from the probe configuration
+type CheckerFactory func(cfg *Config) (HealthChecker, error)
+
+var checkers = map[string]CheckerFactory{}
+
+// registerChecker makes the backend available for the -storage value
+func registerChecker(storage string, factory CheckerFactory) {
+	name := strings.ToLower(storage)
+	if _, ok := checkers[name]; ok {
+		panic(fmt.Sprintf("health checker for storage '%s' is already registered", storage))
+	}
+	checkers[name] = factory
+}
+
+func registeredStorages() []string {
+	names := make([]string, 0, len(checkers))
+	for name := range checkers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sleepContext waits for the delay between the attempts, the wait is stopped when the context is done.
+// The wait which reaches the deadline of the context returns the error of the context.
+func sleepContext(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		if deadline, ok := ctx.Deadline(); ok && !time.Now().Before(deadline) {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		return nil
+	}
+}
+
+// interrupted returns the error of the check stopped by the context, the last error of the storage is kept
+func interrupted(ctxErr error, err error) error {
+	if err == nil {
+		return fmt.Errorf("check is stopped: %w", ctxErr)
+	}
+	return fmt.Errorf("check is stopped: %w, the last error: %w", ctxErr, err)
+}
+
+func newChecker(cfg *Config) (HealthChecker, error) {
+	factory, ok := checkers[strings.ToLower(cfg.storage)]
+	if !ok {
+		return nil, fmt.Errorf("unknown storage '%s', possible values: %s", cfg.storage, strings.Join(registeredStorages(), ", "))
+	}
+	return factory(cfg)
+}
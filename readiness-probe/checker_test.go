@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type stubChecker struct {
+	result Result
+	closed bool
+}
+
+func (s *stubChecker) Check(_ context.Context) Result {
+	return s.result
+}
+
+func (s *stubChecker) Close() {
+	s.closed = true
+}
+
+func TestRegisteredStorages(t *testing.T) {
+	storages := strings.Join(registeredStorages(), ",")
+	for _, name := range []string{cassandra, opensearch, grpcStorage} {
+		if !strings.Contains(storages, name) {
+			t.Errorf("expected storage '%s' to be registered, got %s", name, storages)
+		}
+	}
+}
+
+func TestNewChecker_UnknownStorage(t *testing.T) {
+	_, err := newChecker(&Config{storage: "mongodb"})
+	if err == nil {
+		t.Fatal("expected error for unknown storage")
+	}
+	if !strings.Contains(err.Error(), "unknown storage 'mongodb'") {
+		t.Fatalf("unexpected error message: %v", err)
+	}
+}
+
+func TestNewChecker_CaseInsensitive(t *testing.T) {
+	checker, err := newChecker(&Config{storage: "OpenSearch", hosts: hostList{"http://localhost"}, timeout: 1, minClusterStatus: "yellow"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer checker.Close()
+	if _, ok := checker.(*opensearchChecker); !ok {
+		t.Fatalf("expected opensearchChecker, got %T", checker)
+	}
+}
+
+func TestRegisterChecker_Duplicate(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatal("expected panic for duplicate registration")
+		}
+	}()
+	registerChecker(cassandra, newCassandraChecker)
+}
+
+// blockingChecker blocks the check until the context is done
+type blockingChecker struct{}
+
+func (c *blockingChecker) Check(ctx context.Context) Result {
+	<-ctx.Done()
+	return Result{Healthy: false, Err: ctx.Err()}
+}
+
+func (c *blockingChecker) Close() {}
+
+func TestIsHealth_CheckTimeout(t *testing.T) {
+	server := &Server{checker: &blockingChecker{}, checkTimeout: 50 * time.Millisecond, report: newHealthReport()}
+	if server.isHealth(context.Background()) {
+		t.Fatal("expected the check to fail by the deadline")
+	}
+	if state := server.state.snapshot().State; state != stateNotReady {
+		t.Fatalf("expected NotReady state, got %s", state)
+	}
+	if report := server.report.snapshot(false); !strings.Contains(report.LastError, context.DeadlineExceeded.Error()) {
+		t.Fatalf("expected deadline error in report, got %+v", report)
+	}
+}
+
+func TestIsHealth_CancelledCheckIsDropped(t *testing.T) {
+	server := &Server{checker: &blockingChecker{}, checkTimeout: time.Minute}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	server.isHealth(ctx)
+	if state := server.state.snapshot().State; state != stateUnknown {
+		t.Fatalf("expected the cancelled check not to change the state, got %s", state)
+	}
+}
+
+func TestSleepContext(t *testing.T) {
+	if err := sleepContext(context.Background(), time.Millisecond); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := sleepContext(ctx, time.Hour); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestIsHealth_UsesChecker(t *testing.T) {
+	server := &Server{checker: &stubChecker{result: Result{Healthy: true}}}
+	if !server.isHealth(context.Background()) {
+		t.Fatal("expected isHealth to return the checker result")
+	}
+	server.checker = &stubChecker{result: Result{Healthy: false}}
+	if server.isHealth(context.Background()) {
+		t.Fatal("expected isHealth to return the checker result")
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const composite string = "composite"
+
+// checkSpecs collects the values of the repeated -check flag
+type checkSpecs []string
+
+func (c *checkSpecs) String() string {
+	return strings.Join(*c, " ")
+}
+
+func (c *checkSpecs) Set(value string) error {
+	*c = append(*c, value)
+	return nil
+}
+
+// Policy combines the results of the named checks into the single readiness result
+type Policy struct {
+	name   string
+	quorum int
+}
+
+var quorumPolicy = regexp.MustCompile(`^quorum\((\d+)\)$`)
+
+func parsePolicy(value string, checksCount int) (Policy, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	switch value {
+	case "all":
+		return Policy{name: value, quorum: checksCount}, nil
+	case "any":
+		return Policy{name: value, quorum: 1}, nil
+	}
+	match := quorumPolicy.FindStringSubmatch(value)
+	if match == nil {
+		return Policy{}, fmt.Errorf("unknown policy '%s', possible values: all, any, quorum(n)", value)
+	}
+	n, _ := strconv.Atoi(match[1])
+	if n < 1 || n > checksCount {
+		return Policy{}, fmt.Errorf("policy '%s' requires from 1 to %d healthy checks", value, checksCount)
+	}
+	return Policy{name: value, quorum: n}, nil
+}
+
+func (p Policy) String() string {
+	return p.name
+}
+
+// parseCheckSpec builds the configuration of the named check from the value
+// "name:key=value,key=value", where keys are the names of the storage flags.
+// The value with commas is quoted: key="value,value".
+// The flags explicitly set in the command line are used as defaults for every check.
+func parseCheckSpec(spec string, global *flag.FlagSet) (string, *Config, error) {
+	name, options, found := strings.Cut(spec, ":")
+	name = strings.TrimSpace(name)
+	if !found || name == "" {
+		return "", nil, fmt.Errorf("invalid check '%s', expected format name:key=value,key=value", spec)
+	}
+	cfg := &Config{}
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	cfg.bindFlags(fs)
+	var err error
+	global.Visit(func(f *flag.Flag) {
+		if err == nil && fs.Lookup(f.Name) != nil {
+			err = fs.Set(f.Name, f.Value.String())
+		}
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid check '%s': %w", name, err)
+	}
+	split, err := splitOptions(options)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid check '%s': %w", name, err)
+	}
+	// The list flags are appended by the repeated options, the first option replaces the value from the command line
+	reset := map[string]bool{}
+	for _, option := range split {
+		if strings.TrimSpace(option) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(option, "=")
+		if !ok {
+			return "", nil, fmt.Errorf("invalid option '%s' of check '%s', expected key=value or key=\"value,value\"", option, name)
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) {
+			value = value[1 : len(value)-1]
+		}
+		if f := fs.Lookup(key); f != nil && !reset[key] {
+			if list, ok := f.Value.(interface{ reset() }); ok {
+				list.reset()
+			}
+			reset[key] = true
+		}
+		if err := fs.Set(key, value); err != nil {
+			return "", nil, fmt.Errorf("invalid option '%s' of check '%s': %w", option, name, err)
+		}
+	}
+	return name, cfg, nil
+}
+
+// splitOptions splits the options of the check by the commas outside of the double quotes
+func splitOptions(options string) ([]string, error) {
+	var split []string
+	quoted := false
+	start := 0
+	for i, c := range options {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case c == ',' && !quoted:
+			split = append(split, options[start:i])
+			start = i + 1
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote in options '%s'", options)
+	}
+	return append(split, options[start:]), nil
+}
+
+type namedChecker struct {
+	name    string
+	checker HealthChecker
+}
+
+// compositeChecker runs several named checks in parallel and combines them by the policy
+type compositeChecker struct {
+	checks []namedChecker
+	policy Policy
+}
+
+func newCompositeChecker(checks []namedChecker, policy Policy) *compositeChecker {
+	return &compositeChecker{
+		checks: checks,
+		policy: policy,
+	}
+}
+
+func (c *compositeChecker) Check(ctx context.Context) Result {
+	start := time.Now()
+	results := make([]Result, len(c.checks))
+	var wg sync.WaitGroup
+	for i, check := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = check.checker.Check(ctx)
+			results[i].Name = check.name
+		}()
+	}
+	wg.Wait()
+
+	healthy := 0
+	attempts := 0
+	degraded := false
+	var failed []string
+	var retryAfter time.Duration
+	for _, res := range results {
+		attempts += res.Attempts
+		retryAfter = max(retryAfter, res.RetryAfter)
+		if res.Healthy && res.Degraded {
+			healthy += 1
+			degraded = true
+			slog.Warn("Check is degraded", "check", res.Name, "backend", res.Backend, "error", errorString(res.Err))
+		} else if res.Healthy {
+			healthy += 1
+			slog.Info("Check is healthy", "check", res.Name, "backend", res.Backend, "latency", res.Latency.String())
+		} else {
+			failed = append(failed, res.Name)
+			slog.Error("Check is not healthy", "check", res.Name, "backend", res.Backend, "error", errorString(res.Err))
+		}
+	}
+	var err error
+	if healthy < c.policy.quorum {
+		err = fmt.Errorf("%d of %d checks are healthy, policy '%s' requires %d, failed checks: %s",
+			healthy, len(results), c.policy, c.policy.quorum, strings.Join(failed, ", "))
+	}
+	return Result{
+		Healthy:    err == nil,
+		Degraded:   err == nil && degraded,
+		Backend:    composite,
+		Attempts:   attempts,
+		Latency:    time.Since(start),
+		Err:        err,
+		RetryAfter: retryAfter,
+		Checks:     results,
+	}
+}
+
+func (c *compositeChecker) Close() {
+	for _, check := range c.checks {
+		check.checker.Close()
+	}
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParsePolicy(t *testing.T) {
+	tests := []struct {
+		value  string
+		quorum int
+	}{
+		{"all", 3},
+		{"ANY", 1},
+		{"quorum(2)", 2},
+		{" quorum(3) ", 3},
+	}
+	for _, tt := range tests {
+		p, err := parsePolicy(tt.value, 3)
+		if err != nil {
+			t.Fatalf("unexpected error for '%s': %v", tt.value, err)
+		}
+		if p.quorum != tt.quorum {
+			t.Errorf("expected quorum %d for '%s', got %d", tt.quorum, tt.value, p.quorum)
+		}
+	}
+}
+
+func TestParsePolicy_Invalid(t *testing.T) {
+	for _, value := range []string{"most", "quorum(0)", "quorum(4)", "quorum(x)"} {
+		if _, err := parsePolicy(value, 3); err == nil {
+			t.Errorf("expected error for policy '%s'", value)
+		}
+	}
+}
+
+func TestParseCheckSpec(t *testing.T) {
+	global := flag.NewFlagSet("test", flag.ContinueOnError)
+	globalCfg := &Config{}
+	globalCfg.bindFlags(global)
+	if err := global.Parse([]string{"-namespace=jaeger", "-timeout=10"}); err != nil {
+		t.Fatalf("flag parse error: %v", err)
+	}
+
+	name, cfg, err := parseCheckSpec("archive:storage=opensearch, host=https://os:9200,authSecretName=jaeger-es,timeout=3", global)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if name != "archive" {
+		t.Errorf("expected name 'archive', got '%s'", name)
+	}
+	if cfg.storage != opensearch || cfg.endpoint() != "https://os:9200" || cfg.authSecretName != "jaeger-es" {
+		t.Errorf("unexpected check config: %+v", cfg)
+	}
+	if cfg.namespace != "jaeger" {
+		t.Errorf("expected namespace from global flags, got '%s'", cfg.namespace)
+	}
+	if cfg.timeout != 3 {
+		t.Errorf("expected timeout overridden by check, got %d", cfg.timeout)
+	}
+	if cfg.keyspace != "jaeger" || cfg.errorsCount != 3 {
+		t.Errorf("expected defaults for unset flags, got %+v", cfg)
+	}
+}
+
+func TestParseCheckSpec_Hosts(t *testing.T) {
+	global := flag.NewFlagSet("test", flag.ContinueOnError)
+	globalCfg := &Config{}
+	globalCfg.bindFlags(global)
+	if err := global.Parse([]string{"-host=cassandra-0,cassandra-1"}); err != nil {
+		t.Fatalf("flag parse error: %v", err)
+	}
+
+	_, cfg, err := parseCheckSpec("main:keyspace=jaeger", global)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.endpoint() != "cassandra-0,cassandra-1" {
+		t.Errorf("expected hosts from global flags, got %s", cfg.endpoint())
+	}
+
+	_, cfg, err = parseCheckSpec("archive:storage=opensearch,host=https://os-0:9200,host=https://os-1:9200", global)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.endpoint() != "https://os-0:9200,https://os-1:9200" {
+		t.Errorf("expected hosts replaced by check, got %s", cfg.endpoint())
+	}
+}
+
+func TestParseCheckSpec_QuotedList(t *testing.T) {
+	global := flag.NewFlagSet("test", flag.ContinueOnError)
+	_, cfg, err := parseCheckSpec(`archive:storage=opensearch,healthIndices="jaeger-span-*,jaeger-service-*",host="https://os-0:9200,https://os-1:9200"`, global)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.healthIndices != "jaeger-span-*,jaeger-service-*" {
+		t.Errorf("expected both index patterns, got '%s'", cfg.healthIndices)
+	}
+	if cfg.endpoint() != "https://os-0:9200,https://os-1:9200" {
+		t.Errorf("expected both hosts, got %s", cfg.endpoint())
+	}
+
+	_, _, err = parseCheckSpec("archive:storage=opensearch,healthIndices=jaeger-span-*,jaeger-service-*", global)
+	if err == nil || !strings.Contains(err.Error(), `invalid option 'jaeger-service-*' of check 'archive', expected key=value or key="value,value"`) {
+		t.Errorf("expected the hint to quote the list, got %v", err)
+	}
+}
+
+func TestParseCheckSpec_Invalid(t *testing.T) {
+	global := flag.NewFlagSet("test", flag.ContinueOnError)
+	for _, spec := range []string{"storage=cassandra", ":host=h", "main:host", "main:unknown=1", "main:port=abc", `main:host="h1,h2`} {
+		if _, _, err := parseCheckSpec(spec, global); err == nil {
+			t.Errorf("expected error for check '%s'", spec)
+		}
+	}
+}
+
+func newTestComposite(policy string, results ...bool) (*compositeChecker, []*stubChecker) {
+	var named []namedChecker
+	var stubs []*stubChecker
+	for i, healthy := range results {
+		stub := &stubChecker{result: Result{Healthy: healthy, Backend: cassandra, Attempts: 1}}
+		if !healthy {
+			stub.result.Err = errors.New("down")
+		}
+		stubs = append(stubs, stub)
+		named = append(named, namedChecker{name: string(rune('a' + i)), checker: stub})
+	}
+	p, _ := parsePolicy(policy, len(results))
+	return newCompositeChecker(named, p), stubs
+}
+
+func TestCompositeChecker_Policies(t *testing.T) {
+	tests := []struct {
+		policy  string
+		results []bool
+		healthy bool
+	}{
+		{"all", []bool{true, true}, true},
+		{"all", []bool{true, false}, false},
+		{"any", []bool{false, true}, true},
+		{"any", []bool{false, false}, false},
+		{"quorum(2)", []bool{true, false, true}, true},
+		{"quorum(2)", []bool{true, false, false}, false},
+	}
+	for _, tt := range tests {
+		c, _ := newTestComposite(tt.policy, tt.results...)
+		res := c.Check(context.Background())
+		if res.Healthy != tt.healthy {
+			t.Errorf("policy %s with %v: expected %v, got %v", tt.policy, tt.results, tt.healthy, res.Healthy)
+		}
+	}
+}
+
+func TestCompositeChecker_SubResults(t *testing.T) {
+	c, _ := newTestComposite("all", true, false)
+	res := c.Check(context.Background())
+	if len(res.Checks) != 2 {
+		t.Fatalf("expected 2 sub-results, got %d", len(res.Checks))
+	}
+	if res.Checks[0].Name != "a" || !res.Checks[0].Healthy {
+		t.Errorf("unexpected first sub-result: %+v", res.Checks[0])
+	}
+	if res.Checks[1].Name != "b" || res.Checks[1].Healthy {
+		t.Errorf("unexpected second sub-result: %+v", res.Checks[1])
+	}
+	if res.Attempts != 2 {
+		t.Errorf("expected summed attempts 2, got %d", res.Attempts)
+	}
+	if res.Err == nil || !strings.Contains(res.Err.Error(), "failed checks: b") {
+		t.Errorf("expected error with failed check names, got %v", res.Err)
+	}
+}
+
+func TestCompositeChecker_Degraded(t *testing.T) {
+	c, stubs := newTestComposite("all", true, true)
+	stubs[1].result.Degraded = true
+	stubs[1].result.Err = errors.New("dc2 has 1 of 3 live replicas")
+	res := c.Check(context.Background())
+	if !res.Healthy || !res.Degraded || res.Err != nil {
+		t.Fatalf("expected healthy degraded result, got %+v", res)
+	}
+
+	c, stubs = newTestComposite("all", false, true)
+	stubs[1].result.Degraded = true
+	if res := c.Check(context.Background()); res.Healthy || res.Degraded {
+		t.Fatalf("expected failed result without degradation, got %+v", res)
+	}
+}
+
+func TestCompositeChecker_Close(t *testing.T) {
+	c, stubs := newTestComposite("any", true, true)
+	c.Close()
+	for _, stub := range stubs {
+		if !stub.closed {
+			t.Fatal("expected all sub-checkers to be closed")
+		}
+	}
+}
+
+func TestInitServer_InvalidPolicy_Exit(t *testing.T) {
+	runExitTest(t, "BE_CRASHER_INIT_POLICY", "TestInitServer_InvalidPolicy_Exit", func() {
+		os.Args = []string{"test", "-check=main:storage=grpc,host=127.0.0.1:1", "-policy=quorum(2)"}
+		initServer()
+	})
+}
+
+func TestInitServer_DuplicatedCheck_Exit(t *testing.T) {
+	runExitTest(t, "BE_CRASHER_INIT_DUP_CHECK", "TestInitServer_DuplicatedCheck_Exit", func() {
+		os.Args = []string{"test", "-check=main:storage=grpc,host=127.0.0.1:1", "-check=main:storage=grpc,host=127.0.0.1:2"}
+		initServer()
+	})
+}
+
+func TestInitServer_CheckMissingHost_Exit(t *testing.T) {
+	runExitTest(t, "BE_CRASHER_INIT_CHECK_HOST", "TestInitServer_CheckMissingHost_Exit", func() {
+		os.Args = []string{"test", "-check=main:storage=grpc"}
+		initServer()
+	})
+}
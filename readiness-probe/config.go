@@ -0,0 +1,245 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds the parameters of the storage connection shared by all backends
+type Config struct {
+	storage string
+	hosts   hostList
+	port    int
+
+	errorsCount   int
+	retryCount    int
+	timeout       int
+	retryDelay    int
+	maxRetryDelay int
+
+	tlsEnabled         bool
+	insecureSkipVerify bool
+	caPath             string
+	crtPath            string
+	keyPath            string
+	tlsMinVersion      tlsVersion
+	tlsCipherSuites    cipherSuites
+	tlsServerName      string
+	tlsPins            certPins
+	tlsSecretName      string
+	caSecretName       string
+	caConfigMapName    string
+	caKey              string
+
+	certExpiryWarningDays int
+	certExpiryPolicy      string
+
+	namespace      string
+	authSecretName string
+	authFilesDir   string
+	authType       string
+	tokenPath      string
+	awsRegion      string
+	awsService     string
+	watchSecret    bool
+	keyNames       keyNames
+
+	// Credentials from the Secret or the files
+	authCredentials
+
+	// Cassandra specific parameters
+	keyspace   string
+	datacenter string
+	testTable  string
+
+	consistency         string
+	protoVersion        int
+	numConns            int
+	initialHostLookup   bool
+	hostSelectionPolicy string
+	verifySchema        bool
+	verifyTopology      bool
+	minLiveReplicas     int
+	topologyPolicy      string
+
+	writeCheckTable       string
+	writeCheckTTL         int
+	createWriteCheckTable bool
+
+	// OpenSearch specific parameters
+	healthIndices       string
+	minClusterStatus    string
+	maxUnassignedShards int
+	maxPendingTasks     int
+	verifyIndices       bool
+	useAliases          bool
+	indexPrefix         string
+	indexDateLayout     string
+	writeCheckIndex     string
+	writeIndexTimeout   int
+	writeReadTimeout    int
+	writeDeleteTimeout  int
+
+	// Write check parameters
+	writeCheck bool
+
+	// gRPC specific parameters
+	grpcService string
+}
+
+func (c *Config) bindFlags(fs *flag.FlagSet) {
+	// Common parameters
+	fs.StringVar(&c.storage, "storage", cassandra, "The type of storage for checking probe")
+	fs.Var(&c.hosts, "host", "The host for probe, several hosts are separated by comma or set by the repeated flag")
+	fs.IntVar(&c.port, "port", 0, "The port for probe")
+
+	fs.IntVar(&c.errorsCount, "errors", 3, "The number of allowed errors for checking probe")
+	fs.IntVar(&c.retryCount, "retries", 3, "The number of retries for checking probe")
+	fs.IntVar(&c.timeout, "timeout", 5, "The number of seconds for failing probe by timeout")
+	fs.IntVar(&c.retryDelay, "retryDelay", 2, "The number of seconds before the first retry of the failed request, the delay is doubled for each next retry")
+	fs.IntVar(&c.maxRetryDelay, "maxRetryDelay", 10, "The maximum number of seconds between the retries of the failed requests")
+
+	fs.BoolVar(&c.tlsEnabled, "tlsEnabled", false, "Enabling TLS for connection to the storage")
+	fs.BoolVar(&c.insecureSkipVerify, "insecureSkipVerify", false, "Disabling host verification for TLS")
+
+	// Parameters to fetch information from the Secret
+	fs.StringVar(&c.namespace, "namespace", "tracing", "Namespace for service with probe")
+	fs.StringVar(&c.authSecretName, "authSecretName", "", "Secret name with username and password values")
+	fs.BoolVar(&c.watchSecret, "watchAuthSecret", true, "Enabling the watch of the -authSecretName Secret for reloading the changed credentials without the restart")
+	fs.Var(&c.keyNames, "credentialKeys", "The custom names of the Secret keys and the credential files in format key=name, for example username=user,password=pass")
+	fs.StringVar(&c.authFilesDir, "authFilesDir", "", "The directory with the credential files named as the Secret keys, for example the mounted Secret")
+	fs.StringVar(&c.authType, "authType", basicAuth, "The authentication method: basic, apikey, bearer, serviceaccount, sigv4 or none for the storage without authentication")
+	fs.StringVar(&c.tokenPath, "tokenPath", defaultTokenPath, "The path of the ServiceAccount token for -authType=serviceaccount, for example the projected token with the storage audience")
+	fs.StringVar(&c.awsRegion, "awsRegion", "", "The AWS region of the managed OpenSearch domain for -authType=sigv4")
+	fs.StringVar(&c.awsService, "awsService", "es", "The AWS service name for -authType=sigv4: es for the managed domains or aoss for OpenSearch Serverless")
+	fs.StringVar(&c.caPath, "caPath", "", "The path for ca-cert.pem file, the server is verified with the system trust store without it")
+	fs.StringVar(&c.crtPath, "crtPath", "", "The path for client-cert.pem file, the client certificate is sent only with -crtPath and -keyPath")
+	fs.StringVar(&c.keyPath, "keyPath", "", "The path for client-key.pem file")
+	c.tlsMinVersion = tls.VersionTLS12
+	fs.Var(&c.tlsMinVersion, "tlsMinVersion", "The minimal TLS version: 1.0, 1.1, 1.2 or 1.3")
+	fs.Var(&c.tlsCipherSuites, "tlsCipherSuites", "Comma-separated names of the cipher suites for TLS 1.2 and older, for example TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, the empty value uses the Go defaults")
+	fs.StringVar(&c.tlsServerName, "tlsServerName", "", "The server name for SNI and the certificate verification instead of the host name")
+	fs.StringVar(&c.tlsSecretName, "tlsSecretName", "", "The kubernetes.io/tls Secret with tls.crt, tls.key and optionally ca.crt instead of the files, the Secret is watched for the changes")
+	fs.StringVar(&c.caSecretName, "caSecretName", "", "The Secret with the CA bundle instead of the -caPath file")
+	fs.StringVar(&c.caConfigMapName, "caConfigMapName", "", "The ConfigMap with the CA bundle instead of the -caPath file, for example kube-root-ca.crt")
+	fs.StringVar(&c.caKey, "caKey", defaultCAKey, "The key of the CA bundle in the -tlsSecretName, -caSecretName or -caConfigMapName object")
+	fs.Var(&c.tlsPins, "tlsPinnedKeys", "Comma-separated SHA-256 digests of the subject public keys in base64 or hex, one of the server certificates must have the pinned key")
+	fs.IntVar(&c.certExpiryWarningDays, "certExpiryWarningDays", 30, "The number of days before the expiry of the TLS certificate when the storage is reported as degraded")
+	fs.StringVar(&c.certExpiryPolicy, "certExpiryPolicy", certExpiryDegrade, "The action for the expiring and expired TLS certificates: ignore, degrade or fail, fail makes the storage not ready after the expiry")
+
+	// Cassandra specific parameters
+	fs.StringVar(&c.keyspace, "keyspace", "jaeger", "Keyspace for the Cassandra database")
+	fs.StringVar(&c.datacenter, "datacenter", "datacenter1", "Datacenter for the Cassandra database")
+	fs.StringVar(&c.testTable, "testtable", "service_names", "Table name for getting test data from the Cassandra database")
+	fs.StringVar(&c.consistency, "consistency", "QUORUM", "The consistency level of the Cassandra queries, for example QUORUM, LOCAL_QUORUM or LOCAL_ONE")
+	fs.IntVar(&c.protoVersion, "protoVersion", 4, "The Cassandra protocol version: 3, 4, 5 or 0 for the version discovery")
+	fs.IntVar(&c.numConns, "numConns", 1, "The number of connections to each Cassandra host")
+	fs.BoolVar(&c.initialHostLookup, "initialHostLookup", false, "Enabling discovery of the Cassandra nodes from system.peers, otherwise only the host is used")
+	fs.StringVar(&c.hostSelectionPolicy, "hostSelectionPolicy", dcAwarePolicy, "The Cassandra host selection policy: dcaware or tokenaware, the token aware policy routes the queries to the replicas in the datacenter")
+	fs.BoolVar(&c.verifySchema, "verifySchema", false, "Enabling verification that the keyspace has all Jaeger tables and all nodes agree on the schema version")
+	fs.BoolVar(&c.verifyTopology, "verifyTopology", false, "Enabling verification of the live replicas of the keyspace in each datacenter")
+	fs.IntVar(&c.minLiveReplicas, "minLiveReplicas", 0, "The minimal number of live replicas of the keyspace in each datacenter, 0 requires the quorum of the replication factor")
+	fs.StringVar(&c.topologyPolicy, "topologyPolicy", topologyFail, "The action when the live replicas are below the minimum: fail or degrade")
+	fs.StringVar(&c.writeCheckTable, "writeCheckTable", "readiness_probe", "The table for the canary rows of the write check")
+	fs.IntVar(&c.writeCheckTTL, "writeCheckTTL", 60, "The number of seconds before the canary rows of the write check expire")
+	fs.BoolVar(&c.createWriteCheckTable, "createWriteCheckTable", true, "Enabling creation of the missing write check table, requires the CREATE permission on the keyspace")
+
+	// OpenSearch specific parameters
+	fs.StringVar(&c.healthIndices, "healthIndices", "", "Comma-separated index patterns for scoping the cluster health check, the empty value checks the whole cluster")
+	fs.StringVar(&c.minClusterStatus, "minClusterStatus", "yellow", "The minimal cluster health status for the ready storage: green or yellow")
+	fs.IntVar(&c.maxUnassignedShards, "maxUnassignedShards", -1, "The maximal number of unassigned shards for the ready storage, -1 disables the check")
+	fs.IntVar(&c.maxPendingTasks, "maxPendingTasks", -1, "The maximal number of pending cluster tasks for the ready storage, -1 disables the check")
+	fs.BoolVar(&c.verifyIndices, "verifyIndices", false, "Enabling verification that Jaeger span and service indices or write aliases exist and are writable")
+	fs.BoolVar(&c.useAliases, "useAliases", false, "Verifying the Jaeger write aliases created by the rollover instead of the daily indices")
+	fs.StringVar(&c.indexPrefix, "indexPrefix", "", "The prefix of the Jaeger indices")
+	fs.StringVar(&c.indexDateLayout, "indexDateLayout", "2006-01-02", "The date layout of the Jaeger daily indices")
+	fs.StringVar(&c.writeCheckIndex, "writeCheckIndex", "jaeger-readiness-probe", "The index or alias for the canary document of the write check")
+	fs.IntVar(&c.writeIndexTimeout, "writeIndexTimeout", 5, "The number of seconds for indexing the canary document of the write check")
+	fs.IntVar(&c.writeReadTimeout, "writeReadTimeout", 5, "The number of seconds for reading the canary document of the write check")
+	fs.IntVar(&c.writeDeleteTimeout, "writeDeleteTimeout", 5, "The number of seconds for deleting the canary document of the write check")
+
+	// Write check parameters
+	fs.BoolVar(&c.writeCheck, "writeCheck", false, "Enabling the check of the write path by writing, reading and deleting the canary data")
+
+	// gRPC specific parameters
+	fs.StringVar(&c.grpcService, "grpcService", "", "Service name for the gRPC health check, the empty name checks the whole server")
+}
+
+// validate checks that all required parameters are set
+func (c *Config) validate() error {
+	if len(c.hosts) == 0 {
+		return errors.New("Missing required argument -host")
+	} else if _, ok := credentialKeys[strings.ToLower(c.authType)]; !ok {
+		return fmt.Errorf("Unknown argument -authType '%s', possible values: %s", c.authType, strings.Join(authTypes(), ", "))
+	} else if c.tlsEnabled && (c.crtPath == "") != (c.keyPath == "") {
+		return errors.New("The arguments -crtPath and -keyPath must be set together")
+	} else if c.tlsEnabled && c.caSecretName != "" && c.caConfigMapName != "" {
+		return errors.New("Only one of the arguments -caSecretName, -caConfigMapName can be set")
+	} else if c.tlsEnabled && (c.tlsSecretName != "" || c.caSecretName != "" || c.caConfigMapName != "") &&
+		(c.caPath != "" || c.crtPath != "" || c.keyPath != "") {
+		return errors.New("The arguments -tlsSecretName, -caSecretName, -caConfigMapName can't be combined with -caPath, -crtPath, -keyPath")
+	}
+	switch strings.ToLower(c.certExpiryPolicy) {
+	case certExpiryIgnore, certExpiryDegrade, certExpiryFail:
+	default:
+		return fmt.Errorf("Unknown argument -certExpiryPolicy '%s', possible values: %s, %s, %s", c.certExpiryPolicy, certExpiryIgnore, certExpiryDegrade, certExpiryFail)
+	}
+	if c.certExpiryWarningDays < 0 {
+		return errors.New("The argument -certExpiryWarningDays must not be negative")
+	}
+	if c.retryDelay < 0 || c.maxRetryDelay < c.retryDelay {
+		return errors.New("The argument -retryDelay must not be negative, -maxRetryDelay must not be less than -retryDelay")
+	}
+	if c.writeCheck && c.writeCheckTTL <= 0 {
+		return errors.New("The argument -writeCheckTTL must be positive")
+	}
+	return nil
+}
+
+// retryBackoff returns the delays between the attempts of the single check
+func (c *Config) retryBackoff() backoff {
+	return backoff{base: time.Duration(c.retryDelay) * time.Second, max: time.Duration(c.maxRetryDelay) * time.Second, jitter: retryJitter}
+}
+
+// endpoints returns the hosts with the port if the port is set
+func (c *Config) endpoints() []string {
+	endpoints := make([]string, 0, len(c.hosts))
+	for _, host := range c.hosts {
+		if c.port != 0 {
+			host += ":" + strconv.Itoa(c.port)
+		}
+		endpoints = append(endpoints, host)
+	}
+	return endpoints
+}
+
+// endpoint returns the comma-separated hosts with the port if the port is set
+func (c *Config) endpoint() string {
+	return strings.Join(c.endpoints(), ",")
+}
+
+// hostList collects the hosts from the comma-separated value or the repeated flag
+type hostList []string
+
+func (h *hostList) String() string {
+	return strings.Join(*h, ",")
+}
+
+func (h *hostList) Set(value string) error {
+	for _, host := range strings.Split(value, ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			*h = append(*h, host)
+		}
+	}
+	return nil
+}
+
+// reset removes the hosts copied from the command line before the hosts of the named check are set
+func (h *hostList) reset() {
+	*h = nil
+}
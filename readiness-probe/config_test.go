@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestConfig returns the configuration with the flag defaults changed by the given flags
+func newTestConfig(t *testing.T, args ...string) *Config {
+	t.Helper()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg := &Config{}
+	cfg.bindFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		t.Fatalf("flag parse error: %v", err)
+	}
+	return cfg
+}
+
+func TestHostList_CommaAndRepeated(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg := &Config{}
+	cfg.bindFlags(fs)
+	if err := fs.Parse([]string{"-host=cassandra-0, cassandra-1,", "-host=cassandra-2", "-port=9042"}); err != nil {
+		t.Fatalf("flag parse error: %v", err)
+	}
+	want := []string{"cassandra-0:9042", "cassandra-1:9042", "cassandra-2:9042"}
+	if !reflect.DeepEqual(cfg.endpoints(), want) {
+		t.Fatalf("expected endpoints %v, got %v", want, cfg.endpoints())
+	}
+	if cfg.endpoint() != "cassandra-0:9042,cassandra-1:9042,cassandra-2:9042" {
+		t.Errorf("unexpected endpoint: %s", cfg.endpoint())
+	}
+}
+
+func TestValidate_EmptyHostList(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg := &Config{}
+	cfg.bindFlags(fs)
+	if err := fs.Parse([]string{"-host= , ", "-authSecretName=secret"}); err != nil {
+		t.Fatalf("flag parse error: %v", err)
+	}
+	if err := cfg.validate(); err == nil || err.Error() != "Missing required argument -host" {
+		t.Fatalf("expected missing host error, got %v", err)
+	}
+}
+
+func TestValidate_CertExpiryPolicy(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg := &Config{}
+	cfg.bindFlags(fs)
+	if err := fs.Parse([]string{"-host=cassandra", "-certExpiryPolicy=warn"}); err != nil {
+		t.Fatalf("flag parse error: %v", err)
+	}
+	if err := cfg.validate(); err == nil || !strings.Contains(err.Error(), "-certExpiryPolicy") {
+		t.Fatalf("expected unknown policy error, got %v", err)
+	}
+}
+
+func TestValidate_RetryDelay(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg := &Config{}
+	cfg.bindFlags(fs)
+	if err := fs.Parse([]string{"-host=cassandra", "-retryDelay=20"}); err != nil {
+		t.Fatalf("flag parse error: %v", err)
+	}
+	if err := cfg.validate(); err == nil || !strings.Contains(err.Error(), "-maxRetryDelay") {
+		t.Fatalf("expected retry delay error, got %v", err)
+	}
+	if b := cfg.retryBackoff(); b.base != 20*time.Second || b.max != 10*time.Second {
+		t.Fatalf("unexpected backoff %+v", b)
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// credentialEnv contains the environment variables with the credentials by the credential keys
+var credentialEnv = map[string]string{
+	usernameKey:        "STORAGE_USERNAME",
+	passwordKey:        "STORAGE_PASSWORD",
+	apiKeyKey:          "STORAGE_API_KEY",
+	tokenKey:           "STORAGE_TOKEN",
+	accessKeyIDKey:     "AWS_ACCESS_KEY_ID",
+	secretAccessKeyKey: "AWS_SECRET_ACCESS_KEY",
+	sessionTokenKey:    "AWS_SESSION_TOKEN",
+}
+
+// keyNames maps the credential keys to the custom names of the Secret keys and the credential files
+type keyNames map[string]string
+
+func (k *keyNames) String() string {
+	pairs := make([]string, 0, len(*k))
+	for key, name := range *k {
+		pairs = append(pairs, key+"="+name)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (k *keyNames) Set(value string) error {
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, name, ok := strings.Cut(pair, "=")
+		key, name = strings.TrimSpace(key), strings.TrimSpace(name)
+		if !ok || name == "" {
+			return fmt.Errorf("invalid key name '%s', expected key=name", pair)
+		}
+		if _, known := credentialEnv[key]; !known {
+			return fmt.Errorf("unknown credential key '%s'", key)
+		}
+		if *k == nil {
+			*k = keyNames{}
+		}
+		(*k)[key] = name
+	}
+	return nil
+}
+
+// reset removes the key names copied from the command line before the key names of the named check are set
+func (k *keyNames) reset() {
+	*k = nil
+}
+
+// name returns the custom name of the credential key or the key itself
+func (k keyNames) name(key string) string {
+	if name, ok := k[key]; ok {
+		return name
+	}
+	return key
+}
+
+// credentialSource returns the credential values by the key names, the missing value is empty
+type credentialSource interface {
+	lookup(name string) (string, error)
+	String() string
+}
+
+// secretSource reads the credentials from the Secret, the Secret is read from the API on the first lookup
+type secretSource struct {
+	namespace string
+	name      string
+	newClient func() (kubernetes.Interface, error)
+	secret    *v1.Secret
+	err       error
+}
+
+func (s *secretSource) lookup(name string) (string, error) {
+	if s.secret == nil && s.err == nil {
+		s.secret, s.err = s.read()
+	}
+	if s.err != nil {
+		return "", s.err
+	}
+	return string(s.secret.Data[name]), nil
+}
+
+func (s *secretSource) read() (*v1.Secret, error) {
+	client, err := s.newClient()
+	if err != nil {
+		return nil, err
+	}
+	return client.CoreV1().Secrets(s.namespace).Get(context.TODO(), s.name, metaV1.GetOptions{})
+}
+
+func (s *secretSource) String() string {
+	return fmt.Sprintf("secret '%s/%s'", s.namespace, s.name)
+}
+
+// fileSource reads the credentials from the files in the directory, for example mounted from the Secret
+// or rendered by Vault Agent or the Secrets Store CSI driver
+type fileSource struct {
+	dir string
+}
+
+func (s *fileSource) lookup(name string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, name))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func (s *fileSource) String() string {
+	return fmt.Sprintf("files in '%s'", s.dir)
+}
+
+// envSource reads the credentials from the environment variables, the key names are not applied to them
+type envSource struct{}
+
+func (s *envSource) lookup(name string) (string, error) {
+	return os.Getenv(name), nil
+}
+
+func (s *envSource) String() string {
+	return "environment variables"
+}
+
+// credentialSources returns the sources of the credentials in the order of their priority
+func credentialSources(cfg *Config, newClient func() (kubernetes.Interface, error)) []credentialSource {
+	var sources []credentialSource
+	if cfg.authSecretName != "" {
+		sources = append(sources, &secretSource{namespace: cfg.namespace, name: cfg.authSecretName, newClient: newClient})
+	}
+	if cfg.authFilesDir != "" {
+		sources = append(sources, &fileSource{dir: cfg.authFilesDir})
+	}
+	return append(sources, &envSource{})
+}
+
+// readCredentials reads the credentials of the authentication method from the source,
+// the source must contain all required credentials
+func readCredentials(source credentialSource, authType string, names keyNames) (authCredentials, error) {
+	var creds authCredentials
+	keys := credentialKeys[authType]
+	name := names.name
+	if _, ok := source.(*envSource); ok {
+		name = func(key string) string { return credentialEnv[key] }
+	}
+	for _, key := range keys.required {
+		value, err := source.lookup(name(key))
+		if err != nil {
+			return creds, err
+		}
+		if value == "" {
+			return creds, fmt.Errorf("'%s' is missing", name(key))
+		}
+		creds.setCredential(key, value)
+	}
+	for _, key := range keys.optional {
+		if value, err := source.lookup(name(key)); err == nil {
+			creds.setCredential(key, value)
+		}
+	}
+	return creds, nil
+}
+
+// loadCredentials returns the credentials from the first source which contains all of them
+func loadCredentials(cfg *Config, sources []credentialSource) (authCredentials, credentialSource, error) {
+	var problems []string
+	for _, source := range sources {
+		creds, err := readCredentials(source, cfg.authType, cfg.keyNames)
+		if err == nil {
+			slog.Info(fmt.Sprintf("The credentials for -authType=%s are read from %s", cfg.authType, source))
+			return creds, source, nil
+		}
+		slog.Warn(fmt.Sprintf("Can't read the credentials from %s: %s", source, err.Error()))
+		problems = append(problems, fmt.Sprintf("%s: %s", source, err.Error()))
+	}
+	return authCredentials{}, nil, fmt.Errorf("no credentials for -authType=%s are found, use -authType=%s for the storage without authentication: %s",
+		cfg.authType, noAuth, strings.Join(problems, "; "))
+}
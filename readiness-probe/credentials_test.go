@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestLoadCredentials_SecretWithKeyNames(t *testing.T) {
+	secret := basicAuthSecret("", "")
+	secret.Data = map[string][]byte{"user": []byte("admin"), "pass": []byte("secret")}
+	client := fake.NewSimpleClientset(secret)
+	cfg := newWatchConfig("", "")
+	cfg.keyNames = keyNames{usernameKey: "user", passwordKey: "pass"}
+
+	creds, source, err := loadCredentials(cfg, credentialSources(cfg, func() (kubernetes.Interface, error) { return client, nil }))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := source.(*secretSource); !ok {
+		t.Errorf("expected the secret source, got %s", source)
+	}
+	if creds.user != "admin" || creds.password != "secret" {
+		t.Errorf("unexpected credentials: %+v", creds)
+	}
+}
+
+func TestLoadCredentials_FallbackToFilesAndEnv(t *testing.T) {
+	noKubernetes := func() (kubernetes.Interface, error) { return nil, errors.New("not in cluster") }
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, accessKeyIDKey), []byte("AKID\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &Config{namespace: "tracing", authSecretName: "aws", authFilesDir: dir, authType: sigV4Auth}
+
+	// The files don't contain the secret key, so the environment is used
+	t.Setenv("AWS_ACCESS_KEY_ID", "ENVKEY")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "envsecret")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	creds, source, err := loadCredentials(cfg, credentialSources(cfg, noKubernetes))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := source.(*envSource); !ok || creds.awsAccessKeyID != "ENVKEY" || creds.awsSecretAccessKey != "envsecret" {
+		t.Fatalf("expected the credentials from the environment, got %+v from %s", creds, source)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, secretAccessKeyKey), []byte("filesecret"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	creds, source, err = loadCredentials(cfg, credentialSources(cfg, noKubernetes))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := source.(*fileSource); !ok || creds.awsAccessKeyID != "AKID" || creds.awsSecretAccessKey != "filesecret" {
+		t.Fatalf("expected the credentials from the files, got %+v from %s", creds, source)
+	}
+}
+
+func TestLoadCredentials_NotFound(t *testing.T) {
+	t.Setenv("STORAGE_USERNAME", "")
+	t.Setenv("STORAGE_PASSWORD", "")
+	cfg := &Config{authType: basicAuth}
+	_, _, err := loadCredentials(cfg, credentialSources(cfg, nil))
+	if err == nil || !strings.Contains(err.Error(), "'STORAGE_USERNAME' is missing") || !strings.Contains(err.Error(), "-authType=none") {
+		t.Fatalf("expected the error with the checked sources, got %v", err)
+	}
+}
+
+func TestKeyNames_Flag(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg := &Config{}
+	cfg.bindFlags(fs)
+	if err := fs.Parse([]string{"-credentialKeys=username=user, password=pass", "-credentialKeys=apiKey=key"}); err != nil {
+		t.Fatalf("flag parse error: %v", err)
+	}
+	if got := cfg.keyNames.String(); got != "apiKey=key,password=pass,username=user" {
+		t.Errorf("unexpected key names: %s", got)
+	}
+	if cfg.keyNames.name(tokenKey) != tokenKey {
+		t.Errorf("expected the default name of the key without mapping")
+	}
+	if err := fs.Set("credentialKeys", "login=user"); err == nil {
+		t.Errorf("expected error for the unknown credential key")
+	}
+}
+
+func TestRequestAuthenticator_Anonymous(t *testing.T) {
+	auth, err := newRequestAuthenticator(&Config{authType: noAuth})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "http://opensearch:9200/", nil)
+	if err := auth.authenticate(req, nil); err != nil || req.Header.Get("Authorization") != "" {
+		t.Fatalf("expected the request without credentials, got %q, %v", req.Header.Get("Authorization"), err)
+	}
+}
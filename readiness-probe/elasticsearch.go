@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const elasticsearch string = "elasticsearch"
+
+// Major versions of Elasticsearch and OpenSearch supported by Jaeger
+const (
+	minElasticsearchVersion = 7
+	maxElasticsearchVersion = 8
+	minOpenSearchVersion    = 1
+	maxOpenSearchVersion    = 3
+)
+
+// rootResponse contains the fields of the root endpoint response which identify the cluster
+type rootResponse struct {
+	ClusterName string `json:"cluster_name"`
+	Version     struct {
+		Number       string `json:"number"`
+		Distribution string `json:"distribution"`
+		BuildFlavor  string `json:"build_flavor"`
+	} `json:"version"`
+	Tagline string `json:"tagline"`
+}
+
+// elasticsearchChecker runs the OpenSearch checks against Elasticsearch after the version is detected
+type elasticsearchChecker struct {
+	*opensearchChecker
+	distribution string
+	version      string
+	// detectAgain is set after the failed check, because the cluster can be upgraded while it is not available
+	detectAgain bool
+}
+
+func init() {
+	registerChecker(elasticsearch, newElasticsearchChecker)
+}
+
+func newElasticsearchChecker(cfg *Config) (HealthChecker, error) {
+	checker, err := newOpensearchChecker(cfg)
+	if err != nil {
+		return nil, err
+	}
+	o := checker.(*opensearchChecker)
+	o.backend = elasticsearch
+	return &elasticsearchChecker{opensearchChecker: o}, nil
+}
+
+func (e *elasticsearchChecker) Check(ctx context.Context) Result {
+	start := time.Now()
+	attempts := 0
+	if e.version == "" || e.detectAgain {
+		var err error
+		attempts, err = e.detectVersion(ctx)
+		if err != nil {
+			res := Result{
+				Backend:  elasticsearch,
+				Endpoint: e.activeEndpoint(),
+				Attempts: attempts,
+				Latency:  time.Since(start),
+				Err:      err,
+			}
+			var retryErr *retryAfterError
+			if errors.As(err, &retryErr) {
+				res.RetryAfter = retryErr.delay
+			}
+			return res
+		}
+	}
+	res := e.opensearchChecker.Check(ctx)
+	res.Attempts += attempts
+	res.Latency = time.Since(start)
+	res.Details = map[string]interface{}{"distribution": e.distribution, "version": e.version}
+	e.detectAgain = !res.Healthy
+	return res
+}
+
+// detectVersion reads the distribution and the version from the root endpoint with the -errors and -retries attempts
+// and configures the headers for the API of the detected version. When the root endpoint is not available again,
+// the previously detected version is kept, so the check itself reports the problem. It returns the number of sent requests.
+func (e *elasticsearchChecker) detectVersion(ctx context.Context) (int, error) {
+	res, attempts, err := e.getWithRetries(ctx, "/")
+	if err != nil {
+		if e.version != "" && ctx.Err() == nil {
+			slog.Warn(fmt.Sprintf("Can't detect the version again, %s %s is used: %s", e.distribution, e.version, err.Error()))
+			return attempts, nil
+		}
+		return attempts, fmt.Errorf("can't detect the Elasticsearch version: %w", err)
+	}
+	var root rootResponse
+	if err := json.Unmarshal(res.body, &root); err != nil {
+		return attempts, fmt.Errorf("can't parse the root response: %w", err)
+	}
+	distribution, major, err := clusterMajorVersion(root)
+	if err != nil {
+		return attempts, err
+	}
+	// Elasticsearch 8 answers with the API of the version from the compatible-with header,
+	// it keeps the responses the same after the upgrade to the next major version.
+	// OpenSearch has the same APIs as Elasticsearch 7.x, Jaeger uses the elasticsearch storage for OpenSearch too.
+	e.headers = nil
+	if distribution == elasticsearch && major == 8 {
+		e.headers = http.Header{
+			"Accept":       {"application/vnd.elasticsearch+json;compatible-with=8"},
+			"Content-Type": {"application/vnd.elasticsearch+json;compatible-with=8"},
+		}
+	}
+	e.distribution = distribution
+	e.version = root.Version.Number
+	slog.Info(fmt.Sprintf("%s %s is detected in cluster '%s'", distributionName(distribution), e.version, root.ClusterName))
+	return attempts, nil
+}
+
+// distributionName returns the product name of the distribution for the messages
+func distributionName(distribution string) string {
+	if distribution == opensearch {
+		return "OpenSearch"
+	}
+	return "Elasticsearch"
+}
+
+// clusterMajorVersion returns the distribution and the major version of Elasticsearch or OpenSearch,
+// or the error for the cluster which is not supported by Jaeger
+func clusterMajorVersion(root rootResponse) (string, int, error) {
+	distribution := elasticsearch
+	minVersion, maxVersion := minElasticsearchVersion, maxElasticsearchVersion
+	if strings.EqualFold(root.Version.Distribution, opensearch) {
+		distribution = opensearch
+		minVersion, maxVersion = minOpenSearchVersion, maxOpenSearchVersion
+	} else if root.Version.Distribution != "" || root.Tagline != "You Know, for Search" {
+		return "", 0, fmt.Errorf("cluster '%s' has unsupported distribution '%s' with tagline '%s'", root.ClusterName, root.Version.Distribution, root.Tagline)
+	} else if root.Version.BuildFlavor == "serverless" {
+		return "", 0, fmt.Errorf("cluster '%s' is Elasticsearch Serverless which is not supported by Jaeger", root.ClusterName)
+	}
+	major, err := strconv.Atoi(strings.SplitN(root.Version.Number, ".", 2)[0])
+	if err != nil {
+		return "", 0, fmt.Errorf("cluster '%s' has invalid version '%s'", root.ClusterName, root.Version.Number)
+	}
+	if major < minVersion || major > maxVersion {
+		return "", 0, fmt.Errorf("%s %s of cluster '%s' is not supported by Jaeger, supported versions: %d.x-%d.x",
+			distributionName(distribution), root.Version.Number, root.ClusterName, minVersion, maxVersion)
+	}
+	return distribution, major, nil
+}
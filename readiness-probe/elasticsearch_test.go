@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	elasticsearch7Root = `{"cluster_name":"es","version":{"number":"7.17.10","build_flavor":"default"},"tagline":"You Know, for Search"}`
+	elasticsearch8Root = `{"cluster_name":"es","version":{"number":"8.11.1","build_flavor":"default"},"tagline":"You Know, for Search"}`
+)
+
+// newElasticsearchServer answers the root request with the given body and records the Accept headers of other requests
+func newElasticsearchServer(t *testing.T, root string, accept *[]string) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			_, _ = w.Write([]byte(root))
+			return
+		}
+		*accept = append(*accept, r.Header.Get("Accept"))
+		_, _ = w.Write([]byte(greenClusterHealth))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newTestElasticsearchChecker(t *testing.T, endpoint string, args ...string) *elasticsearchChecker {
+	t.Helper()
+	checker, err := newElasticsearchChecker(newTestConfig(t, append([]string{"-host=" + endpoint, "-errors=1", "-retries=0"}, args...)...))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return checker.(*elasticsearchChecker)
+}
+
+func TestElasticsearchChecker_Version8(t *testing.T) {
+	var accept []string
+	srv := newElasticsearchServer(t, elasticsearch8Root, &accept)
+	res := newTestElasticsearchChecker(t, srv.URL).Check(context.Background())
+	if !res.Healthy || res.Backend != elasticsearch || res.Details["distribution"] != elasticsearch || res.Details["version"] != "8.11.1" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if len(accept) != 1 || accept[0] != "application/vnd.elasticsearch+json;compatible-with=8" {
+		t.Fatalf("expected compatibility header, got %v", accept)
+	}
+}
+
+func TestElasticsearchChecker_Version7(t *testing.T) {
+	var accept []string
+	srv := newElasticsearchServer(t, elasticsearch7Root, &accept)
+	res := newTestElasticsearchChecker(t, srv.URL).Check(context.Background())
+	if !res.Healthy || res.Details["version"] != "7.17.10" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if len(accept) != 1 || accept[0] != "" {
+		t.Fatalf("expected no compatibility header for 7.x, got %v", accept)
+	}
+}
+
+func TestElasticsearchChecker_OpenSearch(t *testing.T) {
+	var accept []string
+	srv := newElasticsearchServer(t, `{"cluster_name":"os","version":{"distribution":"opensearch","number":"2.11.0"},"tagline":"The OpenSearch Project: https://opensearch.org/"}`, &accept)
+	res := newTestElasticsearchChecker(t, srv.URL).Check(context.Background())
+	if !res.Healthy || res.Details["distribution"] != opensearch || res.Details["version"] != "2.11.0" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if len(accept) != 1 || accept[0] != "" {
+		t.Fatalf("expected no compatibility header for OpenSearch, got %v", accept)
+	}
+}
+
+func TestElasticsearchChecker_DetectedAgainAfterFailure(t *testing.T) {
+	root := elasticsearch7Root
+	healthy := true
+	rootRequests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			rootRequests += 1
+			_, _ = w.Write([]byte(root))
+			return
+		}
+		if !healthy {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte(greenClusterHealth))
+	}))
+	defer srv.Close()
+
+	checker := newTestElasticsearchChecker(t, srv.URL)
+	checker.Check(context.Background())
+	checker.Check(context.Background())
+	if rootRequests != 1 {
+		t.Fatalf("expected the version to be detected once, got %d requests", rootRequests)
+	}
+	healthy = false
+	checker.Check(context.Background())
+	healthy = true
+	root = elasticsearch8Root
+	if res := checker.Check(context.Background()); !res.Healthy || res.Details["version"] != "8.11.1" || rootRequests != 2 {
+		t.Fatalf("expected the version to be detected again, got %+v after %d requests", res, rootRequests)
+	}
+}
+
+func TestElasticsearchChecker_DetectionRetried(t *testing.T) {
+	rootFailures := 1
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			if rootFailures > 0 {
+				rootFailures -= 1
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			_, _ = w.Write([]byte(elasticsearch7Root))
+			return
+		}
+		_, _ = w.Write([]byte(greenClusterHealth))
+	}))
+	defer srv.Close()
+
+	checker := newTestElasticsearchChecker(t, srv.URL, "-retries=2")
+	checker.retry = backoff{base: time.Millisecond}
+	res := checker.Check(context.Background())
+	if !res.Healthy || res.Details["version"] != "7.17.10" {
+		t.Fatalf("expected the detection to be retried, got %+v", res)
+	}
+	if res.Attempts != 3 {
+		t.Errorf("expected 2 detection attempts and 1 health attempt, got %d", res.Attempts)
+	}
+}
+
+func TestElasticsearchChecker_KeepsVersionWhenDetectionFails(t *testing.T) {
+	rootAvailable := true
+	healthy := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			if !rootAvailable {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			_, _ = w.Write([]byte(elasticsearch8Root))
+			return
+		}
+		if !healthy {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte(greenClusterHealth))
+	}))
+	defer srv.Close()
+
+	checker := newTestElasticsearchChecker(t, srv.URL)
+	checker.Check(context.Background())
+	healthy = false
+	checker.Check(context.Background())
+	healthy = true
+	rootAvailable = false
+	if res := checker.Check(context.Background()); !res.Healthy || res.Details["version"] != "8.11.1" {
+		t.Fatalf("expected the previously detected version to be used, got %+v", res)
+	}
+}
+
+func TestElasticsearchMajorVersion_Unsupported(t *testing.T) {
+	tests := []struct {
+		root string
+		want string
+	}{
+		{`{"cluster_name":"os","version":{"distribution":"opensearch","number":"4.0.0"},"tagline":"The OpenSearch Project: https://opensearch.org/"}`, "OpenSearch 4.0.0 of cluster 'os' is not supported by Jaeger, supported versions: 1.x-3.x"},
+		{`{"cluster_name":"es","version":{"number":"6.8.23"},"tagline":"You Know, for Search"}`, "Elasticsearch 6.8.23 of cluster 'es' is not supported by Jaeger, supported versions: 7.x-8.x"},
+		{`{"cluster_name":"es","version":{"number":"9.0.0"},"tagline":"You Know, for Search"}`, "Elasticsearch 9.0.0 of cluster 'es' is not supported by Jaeger"},
+		{`{"cluster_name":"es","version":{"number":"8.11.0","build_flavor":"serverless"},"tagline":"You Know, for Search"}`, "Elasticsearch Serverless"},
+		{`{"cluster_name":"x","version":{"number":"1.0"},"tagline":"Something else"}`, "unsupported distribution"},
+	}
+	for _, tt := range tests {
+		var accept []string
+		srv := newElasticsearchServer(t, tt.root, &accept)
+		res := newTestElasticsearchChecker(t, srv.URL).Check(context.Background())
+		if res.Healthy || res.Err == nil || !strings.Contains(res.Err.Error(), tt.want) {
+			t.Errorf("expected error '%s', got %v", tt.want, res.Err)
+		}
+		if len(accept) != 0 {
+			t.Errorf("expected no checks for unsupported cluster, got %d requests", len(accept))
+		}
+	}
+}
+
+func TestNewChecker_Elasticsearch(t *testing.T) {
+	checker, err := newChecker(&Config{storage: "Elasticsearch", hosts: hostList{"http://localhost"}, timeout: 1, minClusterStatus: "yellow"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := checker.(*elasticsearchChecker); !ok {
+		t.Fatalf("expected elasticsearch checker, got %T", checker)
+	}
+}
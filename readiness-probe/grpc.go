@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const grpcStorage string = "grpc"
+
+type grpcChecker struct {
+	conn        *grpc.ClientConn
+	certs       *certReloader
+	health      healthpb.HealthClient
+	endpoint    string
+	service     string
+	timeout     time.Duration
+	errorsCount int
+	retry       backoff
+}
+
+func init() {
+	registerChecker(grpcStorage, newGrpcChecker)
+}
+
+func newGrpcChecker(cfg *Config) (HealthChecker, error) {
+	if len(cfg.hosts) > 1 {
+		return nil, fmt.Errorf("storage '%s' supports a single -host, got %s", grpcStorage, cfg.hosts.String())
+	}
+	checker, err := createGrpcChecker(cfg.endpoint(), cfg.grpcService, cfg.tlsEnabled, cfg.tlsOptions(), time.Duration(cfg.timeout), cfg.errorsCount)
+	if err != nil {
+		return nil, err
+	}
+	checker.retry = cfg.retryBackoff()
+	return checker, nil
+}
+
+func createGrpcChecker(endpoint string, service string, tlsEnabled bool, tlsOpts tlsOptions, timeout time.Duration, errorsCount int) (*grpcChecker, error) {
+	transportCredentials := insecure.NewCredentials()
+	var certs *certReloader
+	if tlsEnabled {
+		var tlsConfig *tls.Config
+		tlsConfig, certs = createTLSConfig(tlsOpts)
+		transportCredentials = &hostCredentials{TransportCredentials: credentials.NewTLS(tlsConfig), tlsConfig: tlsConfig}
+	}
+	// grpc.NewClient doesn't connect, so the error is only possible for the malformed endpoint
+	conn, err := grpc.NewClient(endpoint, grpc.WithTransportCredentials(transportCredentials))
+	if err != nil {
+		return nil, fmt.Errorf("can't create gRPC client: %w", err)
+	}
+	return &grpcChecker{
+		conn:        conn,
+		certs:       certs,
+		health:      healthpb.NewHealthClient(conn),
+		endpoint:    endpoint,
+		service:     service,
+		timeout:     timeout * time.Second,
+		errorsCount: errorsCount,
+	}, nil
+}
+
+// hostCredentials verifies the host of the authority on every handshake, the IP address is verified too
+type hostCredentials struct {
+	credentials.TransportCredentials
+	tlsConfig *tls.Config
+}
+
+func (c *hostCredentials) ClientHandshake(ctx context.Context, authority string, conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	host := authority
+	if h, _, err := net.SplitHostPort(authority); err == nil {
+		host = h
+	}
+	return credentials.NewTLS(tlsConfigFor(c.tlsConfig, host)).ClientHandshake(ctx, authority, conn)
+}
+
+func (c *hostCredentials) Clone() credentials.TransportCredentials {
+	return &hostCredentials{TransportCredentials: c.TransportCredentials.Clone(), tlsConfig: c.tlsConfig}
+}
+
+func (g *grpcChecker) Check(ctx context.Context) Result {
+	start := time.Now()
+	attempts, err := g.healthCheck(ctx)
+	return Result{
+		Healthy:  err == nil,
+		Backend:  grpcStorage,
+		Endpoint: g.endpoint,
+		Attempts: attempts,
+		Latency:  time.Since(start),
+		Err:      err,
+	}
+}
+
+func (g *grpcChecker) certificates() *certReloader {
+	return g.certs
+}
+
+func (g *grpcChecker) Close() {
+	if err := g.conn.Close(); err != nil {
+		slog.Error(fmt.Sprintf("Error closing gRPC connection: %s", err.Error()))
+	}
+	if g.certs != nil {
+		g.certs.close()
+	}
+}
+
+// healthCheck returns the number of made attempts and the last error, nil error means the storage is healthy
+func (g *grpcChecker) healthCheck(ctx context.Context) (int, error) {
+	errors := 0
+	var err error
+	for errors < g.errorsCount {
+		callCtx, cancel := context.WithTimeout(ctx, g.timeout)
+		var res *healthpb.HealthCheckResponse
+		res, err = g.health.Check(callCtx, &healthpb.HealthCheckRequest{Service: g.service})
+		cancel()
+		if err != nil {
+			slog.Error("Can't check the gRPC health. The error from server: ", "error", err.Error())
+		} else if res.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+			err = fmt.Errorf("the gRPC service '%s' has status %s", g.service, res.GetStatus())
+			slog.Error(err.Error())
+		} else {
+			return errors + 1, nil
+		}
+		errors += 1
+		slog.Info(fmt.Sprintf("Remaining attempts: %d", g.errorsCount-errors))
+		if errors >= g.errorsCount {
+			return errors, err
+		}
+		delay := g.retry.delay(errors)
+		slog.Info(fmt.Sprintf("Sleep for %s and try again", delay.Round(time.Millisecond)))
+		if waitErr := sleepContext(ctx, delay); waitErr != nil {
+			return errors, interrupted(waitErr, err)
+		}
+	}
+	return errors, err
+}
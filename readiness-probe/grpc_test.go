@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// startHealthServer runs the standard gRPC health service on a random local port
+func startHealthServer(t *testing.T) (string, *health.Server) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	srv := grpc.NewServer()
+	hs := health.NewServer()
+	healthpb.RegisterHealthServer(srv, hs)
+	go func() {
+		_ = srv.Serve(lis)
+	}()
+	t.Cleanup(srv.Stop)
+	return lis.Addr().String(), hs
+}
+
+func TestGrpcHealth_Serving(t *testing.T) {
+	addr, _ := startHealthServer(t)
+	s, err := createGrpcChecker(addr, "", false, tlsOptions{}, 1, 1)
+	if err != nil {
+		t.Fatalf("failed to create gRPC checker: %v", err)
+	}
+	if !s.Check(context.Background()).Healthy {
+		t.Fatal("expected true for serving gRPC server")
+	}
+}
+
+func TestGrpcHealth_ServiceNotServing(t *testing.T) {
+	addr, hs := startHealthServer(t)
+	hs.SetServingStatus("jaeger.storage", healthpb.HealthCheckResponse_NOT_SERVING)
+	s, err := createGrpcChecker(addr, "jaeger.storage", false, tlsOptions{}, 1, 1)
+	if err != nil {
+		t.Fatalf("failed to create gRPC checker: %v", err)
+	}
+	if s.Check(context.Background()).Healthy {
+		t.Fatal("expected false for not serving gRPC service")
+	}
+}
+
+func TestGrpcHealth_UnknownService(t *testing.T) {
+	addr, _ := startHealthServer(t)
+	s, err := createGrpcChecker(addr, "unknown", false, tlsOptions{}, 1, 1)
+	if err != nil {
+		t.Fatalf("failed to create gRPC checker: %v", err)
+	}
+	if s.Check(context.Background()).Healthy {
+		t.Fatal("expected false for unknown gRPC service")
+	}
+}
+
+func TestGrpcHealth_Unavailable(t *testing.T) {
+	s, err := createGrpcChecker("127.0.0.1:1", "", false, tlsOptions{}, 1, 1)
+	if err != nil {
+		t.Fatalf("failed to create gRPC checker: %v", err)
+	}
+	defer s.Close()
+	res := s.Check(context.Background())
+	if res.Healthy || res.Err == nil {
+		t.Fatal("expected failed result for unavailable gRPC server")
+	}
+	if res.Backend != grpcStorage || res.Endpoint != "127.0.0.1:1" || res.Attempts != 1 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+}
+
+func TestCreateGrpcChecker_TLS_InsecureSkipVerify(t *testing.T) {
+	gc, err := createGrpcChecker("127.0.0.1:1", "", true, tlsOptions{insecureSkipVerify: true}, 1, 1)
+	if err != nil || gc.conn == nil {
+		t.Fatal("expected non-nil gRPC client")
+	}
+	if gc.timeout != time.Second {
+		t.Fatalf("expected timeout 1s, got %s", gc.timeout)
+	}
+}
+
+func TestNewChecker_Grpc(t *testing.T) {
+	addr, _ := startHealthServer(t)
+	host, port, _ := net.SplitHostPort(addr)
+	p, _ := strconv.Atoi(port)
+	checker, err := newChecker(&Config{storage: "gRPC", hosts: hostList{host}, port: p, timeout: 1, errorsCount: 1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer checker.Close()
+	if !checker.Check(context.Background()).Healthy {
+		t.Fatal("expected registered gRPC checker to be healthy")
+	}
+}
+
+func TestNewGrpcChecker_SeveralHosts(t *testing.T) {
+	if _, err := newChecker(&Config{storage: grpcStorage, hosts: hostList{"a:1", "b:1"}, timeout: 1}); err == nil {
+		t.Fatal("expected error for several hosts")
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Exit codes of the probe, the invalid flags exit with 2 from the flag package
+const (
+	exitOK = 0
+	// exitFailure is returned for the invalid configuration and for the failed HTTP server
+	exitFailure = 1
+	// exitShutdownTimeout is returned when the requests or the check are not finished within -shutdownTimeout
+	exitShutdownTimeout = 3
+)
+
+// run serves the probe endpoints and runs the checks until the context is cancelled,
+// then drains the HTTP server, stops the checks and closes the backends within shutdownTimeout.
+// The stop function restores the default signal handling, so the second signal kills the process.
+func (s *Server) run(ctx context.Context, stop func()) int {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", s.readinessProbe)
+	mux.HandleFunc("/livez", s.livenessProbe)
+	mux.Handle("/metrics", metricsHandler())
+	server := &http.Server{Handler: mux}
+
+	listener, err := net.Listen("tcp", "0.0.0.0:"+strconv.Itoa(s.servicePort))
+	if err != nil {
+		slog.Error(err.Error())
+		s.checker.Close()
+		return exitFailure
+	}
+	slog.Info(fmt.Sprintf("The service is listening on %s", listener.Addr().String()))
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(listener)
+	}()
+
+	checkCtx, cancelChecks := context.WithCancel(ctx)
+	defer cancelChecks()
+	checksDone := make(chan struct{})
+	go func() {
+		defer close(checksDone)
+		s.checkLoop(checkCtx)
+	}()
+
+	code := exitOK
+	select {
+	case <-ctx.Done():
+		slog.Info("Shutting down gracefully, press Ctrl+C again to force")
+	case err := <-serveErr:
+		slog.Error(fmt.Sprintf("The service is stopped: %s", err.Error()))
+		code = exitFailure
+	}
+	stop()
+	cancelChecks()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error(fmt.Sprintf("Error shutting down the service: %s", err.Error()))
+		code = max(code, exitShutdownTimeout)
+	}
+	select {
+	case <-checksDone:
+	case <-shutdownCtx.Done():
+		// The backends are not closed under the running check
+		slog.Error(fmt.Sprintf("The check is not finished within the shutdown timeout %s", s.shutdownTimeout))
+		return max(code, exitShutdownTimeout)
+	}
+	s.checker.Close()
+	slog.Info("The service is stopped")
+	return code
+}
+
+// checkLoop runs the checks by the schedule until the context is cancelled
+func (s *Server) checkLoop(ctx context.Context) {
+	delay := s.schedule.first()
+	slog.Info(fmt.Sprintf("Readiness probe process is starting in %s", delay.Round(time.Millisecond)))
+	for {
+		if err := sleepContext(ctx, delay); err != nil {
+			return
+		}
+		res := s.check(ctx)
+		delay = s.schedule.next(res.Healthy, s.state.ready())
+		if res.RetryAfter > delay {
+			slog.Info(fmt.Sprintf("The storage asks to retry after %s", res.RetryAfter))
+			delay = res.RetryAfter
+		}
+		slog.Info(fmt.Sprintf("Sleep for %s and try again", delay.Round(time.Millisecond)))
+	}
+}
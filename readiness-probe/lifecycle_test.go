@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// signalingChecker reports every check to the channel and blocks the check until it is released
+type signalingChecker struct {
+	checks  chan struct{}
+	release chan struct{}
+	closed  bool
+}
+
+func (c *signalingChecker) Check(_ context.Context) Result {
+	c.checks <- struct{}{}
+	if c.release != nil {
+		<-c.release
+	}
+	return Result{Healthy: true}
+}
+
+func (c *signalingChecker) Close() {
+	c.closed = true
+}
+
+func TestRun_GracefulShutdown(t *testing.T) {
+	checker := &signalingChecker{checks: make(chan struct{}, 1)}
+	s := &Server{servicePort: 0, shutdownTimeout: time.Second, checker: checker}
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := false
+	code := make(chan int)
+	go func() {
+		code <- s.run(ctx, func() { stopped = true })
+	}()
+	<-checker.checks
+	cancel()
+	if got := <-code; got != exitOK {
+		t.Errorf("expected exit code %d, got %d", exitOK, got)
+	}
+	if !checker.closed || !stopped {
+		t.Errorf("expected the checker to be closed and the signals to be stopped, got %v, %v", checker.closed, stopped)
+	}
+}
+
+func TestRun_ListenError(t *testing.T) {
+	listener, err := net.Listen("tcp", "0.0.0.0:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	checker := &signalingChecker{checks: make(chan struct{}, 1)}
+	s := &Server{servicePort: listener.Addr().(*net.TCPAddr).Port, shutdownTimeout: time.Second, checker: checker}
+	if code := s.run(context.Background(), func() {}); code != exitFailure {
+		t.Errorf("expected exit code %d, got %d", exitFailure, code)
+	}
+	if !checker.closed {
+		t.Error("expected the checker to be closed")
+	}
+}
+
+func TestRun_CheckNotFinishedWithinTimeout(t *testing.T) {
+	checker := &signalingChecker{checks: make(chan struct{}, 1), release: make(chan struct{})}
+	defer close(checker.release)
+	s := &Server{servicePort: 0, shutdownTimeout: 100 * time.Millisecond, checker: checker}
+	ctx, cancel := context.WithCancel(context.Background())
+	code := make(chan int)
+	go func() {
+		code <- s.run(ctx, func() {})
+	}()
+	<-checker.checks
+	cancel()
+	if got := <-code; got != exitShutdownTimeout {
+		t.Errorf("expected exit code %d, got %d", exitShutdownTimeout, got)
+	}
+	if checker.closed {
+		t.Error("expected the checker not to be closed under the running check")
+	}
+}
+
+// countingChecker counts the checks and returns the same result
+type countingChecker struct {
+	result Result
+	checks atomic.Int32
+}
+
+func (c *countingChecker) Check(_ context.Context) Result {
+	c.checks.Add(1)
+	return c.result
+}
+
+func (c *countingChecker) Close() {}
+
+func TestCheckLoop_WaitsForRetryAfter(t *testing.T) {
+	checker := &countingChecker{result: Result{Healthy: false, RetryAfter: time.Hour}}
+	server := &Server{checker: checker, schedule: scheduler{interval: time.Millisecond}}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	server.checkLoop(ctx)
+	if got := checker.checks.Load(); got != 1 {
+		t.Fatalf("expected the next check to wait for Retry-After, got %d checks", got)
+	}
+}
@@ -3,7 +3,7 @@ package main
 import (
 	"context"
 	"crypto/tls"
-	"crypto/x509"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
@@ -11,310 +11,196 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
-	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
-	v1 "k8s.io/api/core/v1"
-	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	ctrl "sigs.k8s.io/controller-runtime"
-
-	"github.com/gocql/gocql"
 )
 
 var Logger = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
 
-var isHealth = false
-
-type HttpClient struct {
-	client   http.Client
-	user     string
-	password string
-}
-
 type Server struct {
-	endpoint        string
-	errorsCount     int
-	retryCount      int
-	storage         string
 	servicePort     int
 	shutdownTimeout time.Duration
-	tlsEnabled      bool
-	opensearch      *HttpClient
-	cassandra       CassandraSession
-	keyspace        string
-	testTable       string
-}
-
-// CassandraSession interface for mocking
-type CassandraSession interface {
-	Query(stmt string, values ...interface{}) Query
-	Close()
-}
-
-// Query interface for mocking
-type Query interface {
-	Exec() error
+	checkTimeout    time.Duration
+	schedule        scheduler
+	checker         HealthChecker
+	report          *healthReport
+	state           healthState
 }
 
-// Real implementations that wrap gocql types
-type realCassandraSession struct {
-	session *gocql.Session
-}
-
-func (r *realCassandraSession) Query(stmt string, values ...interface{}) Query {
-	return &realQuery{query: r.session.Query(stmt, values...)}
-}
-
-func (r *realCassandraSession) Close() {
-	r.session.Close()
-}
-
-type realQuery struct {
-	query *gocql.Query
-}
-
-func (r *realQuery) Exec() error {
-	return r.query.Exec()
-}
-
-const (
-	cassandra string = "cassandra"
-)
-
 func main() {
 	slog.SetDefault(Logger)
 	slog.Info("Starting the service")
 	s := initServer()
-	host := "0.0.0.0:" + strconv.Itoa(s.servicePort)
-	mux := http.NewServeMux()
-	mux.HandleFunc("/health", s.readinessProbe)
-	mux.HandleFunc("/livez", s.livenessProbe)
-
-	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
-	defer stop()
-
-	server := &http.Server{
-		Addr:    host,
-		Handler: mux,
-	}
-
-	go func() {
-		slog.Info(fmt.Sprintf("The service is listening on 0.0.0.0:%d", s.servicePort))
-		if err := server.ListenAndServe(); err != nil {
-			if err != http.ErrServerClosed {
-				slog.Error(err.Error())
-				os.Exit(1)
-			}
-		}
-	}()
-
-	go func() {
-		slog.Info("Readiness probe process is starting")
-		for {
-			isHealth = s.isHealth()
-			slog.Info("Sleep for 10 sec and try again")
-			time.Sleep(10 * time.Second)
-		}
-	}()
-
-	<-ctx.Done()
 
+	// kubelet stops the container with SIGTERM, SIGINT is sent by Ctrl+C
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	code := s.run(ctx, stop)
 	stop()
-	slog.Info("Shutting down gracefully, press Ctrl+C again to force")
-
-	timeoutCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	go func() {
-		if err := server.Shutdown(timeoutCtx); err != nil {
-			slog.Error(err.Error())
-			os.Exit(1)
-		}
-		os.Exit(0)
-	}()
+	os.Exit(code)
 }
 
 func initServer() *Server {
 	// Probe service parameters
 	servicePort := flag.Int("servicePort", 8080, "The number of port for running service")
 	shutdownTimeout := flag.Int("shutdownTimeout", 5, "The number of seconds for graceful shutdown before connections are cancelled")
-
-	// Common parameters
-	storage := flag.String("storage", "cassandra", "The type of storage for checking probe")
-	host := flag.String("host", "", "The host for probe")
-	port := flag.Int("port", 0, "The port for probe")
-
-	errors := flag.Int("errors", 3, "The number of allowed errors for checking probe")
-	retries := flag.Int("retries", 3, "The number of retries for checking probe")
-	timeout := flag.Int("timeout", 5, "The number of seconds for failing probe by timeout")
-
-	tlsEnabled := flag.Bool("tlsEnabled", false, "Enabling TLS for connection to the storage")
-	insecureSkipVerify := flag.Bool("insecureSkipVerify", false, "Disabling host verification for TLS")
-
-	// Parameters to fetch information from the Secret
-	namespace := flag.String("namespace", "tracing", "Namespace for service with probe")
-	authSecretName := flag.String("authSecretName", "", "Secret name with username and password values")
-	ca := flag.String("caPath", "", "The path for ca-cert.pem file")
-	crt := flag.String("crtPath", "", "The path for client-cert.pem file")
-	key := flag.String("keyPath", "", "The path for client-key.pem file")
-
-	// Cassandra specific parameters
-	keyspace := flag.String("keyspace", "jaeger", "Keyspace for the Cassandra database")
-	datacenter := flag.String("datacenter", "datacenter1", "Datacenter for the Cassandra database")
-	testtable := flag.String("testtable", "service_names", "Table name for getting test data from the Cassandra database")
-
-	var user, pass string
+	checkTimeout := flag.Int("checkTimeout", 60, "The number of seconds for the whole check cycle with all retries before the check fails, 0 disables the deadline")
+	checkInterval := flag.Int("checkInterval", 10, "The number of seconds between the checks of the ready storage")
+	maxCheckInterval := flag.Int("maxCheckInterval", 60, "The maximum number of seconds between the checks of the failing storage")
+	recoveryInterval := flag.Int("recoveryInterval", 2, "The number of seconds between the checks of the recovering storage")
+	checkJitter := flag.Float64("checkJitter", 0.1, "The fraction of the interval between the checks which is randomly added or subtracted")
+	startupJitter := flag.Int("startupJitter", 5, "The maximum number of seconds of the random delay of the first check")
+	successThreshold := flag.Int("successThreshold", 1, "The number of consecutive successful checks for the not ready storage to become ready")
+	failureThreshold := flag.Int("failureThreshold", 3, "The number of consecutive failed checks for the ready storage to become not ready")
+
+	// Multiple checks parameters
+	var checks checkSpecs
+	flag.Var(&checks, "check", "The named check in format name:key=value,key=value with storage parameters, can be repeated")
+	policy := flag.String("policy", "all", "The policy for combining named checks: all, any or quorum(n)")
+
+	cfg := &Config{}
+	cfg.bindFlags(flag.CommandLine)
 	flag.Parse()
-	if *host == "" {
-		slog.Error("Missing required argument -host")
+	if *successThreshold < 1 || *failureThreshold < 1 {
+		slog.Error("The arguments -successThreshold and -failureThreshold must be positive")
 		os.Exit(1)
-	} else if *authSecretName == "" {
-		slog.Error("Missing required argument -authSecretName")
+	}
+	if *checkTimeout < 0 {
+		slog.Error("The argument -checkTimeout must not be negative")
 		os.Exit(1)
-	} else if *tlsEnabled {
-		if !*insecureSkipVerify && (*ca == "" || *crt == "" || *key == "") {
-			slog.Error("Missing one of the required arguments -caPath, -crtPath, -keyPath")
-			os.Exit(1)
-		}
 	}
-	secret := readSecret(*namespace, *authSecretName)
-	if secret == nil {
-		slog.Error("Failed to read secret")
+	if *checkInterval < 1 || *maxCheckInterval < *checkInterval || *recoveryInterval < 1 {
+		slog.Error("The arguments -checkInterval and -recoveryInterval must be positive, -maxCheckInterval must not be less than -checkInterval")
 		os.Exit(1)
 	}
-	user = readFromSecret(secret, v1.BasicAuthUsernameKey)
-	pass = readFromSecret(secret, v1.BasicAuthPasswordKey)
-	endpoint := *host
-	if *port != 0 {
-		endpoint += ":" + strconv.Itoa(*port)
+	if *checkJitter < 0 || *checkJitter >= 1 || *startupJitter < 0 {
+		slog.Error("The argument -checkJitter must be in range [0, 1), -startupJitter must not be negative")
+		os.Exit(1)
 	}
-	var opensearchClient *HttpClient
-	var cassandraSession CassandraSession
-	if *storage == "opensearch" {
-		opensearchClient = createHttpClient(user, pass, *tlsEnabled, *ca, *crt, *key, *insecureSkipVerify, time.Duration(*timeout))
+
+	var checker HealthChecker
+	if len(checks) == 0 {
+		checker = createChecker(cfg)
 	} else {
-		cassandraClient := createCassandraClient(*host, *port, user, pass, *tlsEnabled, *ca, *crt, *key, *insecureSkipVerify, time.Duration(*timeout), *errors, *datacenter, *keyspace)
-		cassandraSession = &realCassandraSession{session: cassandraClient}
+		p, err := parsePolicy(*policy, len(checks))
+		if err != nil {
+			slog.Error(err.Error())
+			os.Exit(1)
+		}
+		names := map[string]bool{}
+		named := make([]namedChecker, 0, len(checks))
+		for _, spec := range checks {
+			name, checkCfg, err := parseCheckSpec(spec, flag.CommandLine)
+			if err != nil {
+				slog.Error(err.Error())
+				os.Exit(1)
+			}
+			if names[name] {
+				slog.Error(fmt.Sprintf("Duplicated check name '%s'", name))
+				os.Exit(1)
+			}
+			names[name] = true
+			slog.Info(fmt.Sprintf("Creating the check '%s' for storage '%s'", name, checkCfg.storage))
+			named = append(named, namedChecker{name: name, checker: createChecker(checkCfg)})
+		}
+		checker = newCompositeChecker(named, p)
 	}
 	return &Server{
-		endpoint:        endpoint,
-		tlsEnabled:      *tlsEnabled,
-		retryCount:      *retries,
-		errorsCount:     *errors,
-		storage:         *storage,
 		servicePort:     *servicePort,
-		shutdownTimeout: time.Duration(*shutdownTimeout),
-		opensearch:      opensearchClient,
-		cassandra:       cassandraSession,
-		testTable:       *testtable,
-		keyspace:        *keyspace,
+		shutdownTimeout: time.Duration(*shutdownTimeout) * time.Second,
+		checkTimeout:    time.Duration(*checkTimeout) * time.Second,
+		schedule: scheduler{
+			interval:         time.Duration(*checkInterval) * time.Second,
+			maxInterval:      time.Duration(*maxCheckInterval) * time.Second,
+			recoveryInterval: time.Duration(*recoveryInterval) * time.Second,
+			startupJitter:    time.Duration(*startupJitter) * time.Second,
+			jitter:           *checkJitter,
+		},
+		checker: checker,
+		report:  newHealthReport(),
+		state: healthState{
+			successThreshold: *successThreshold,
+			failureThreshold: *failureThreshold,
+		},
 	}
 }
 
-func readSecret(namespace string, secretName string) *v1.Secret {
-	config, err := ctrl.GetConfig()
-	if err != nil {
+// createChecker validates the configuration, reads credentials and creates the checker for the storage
+func createChecker(cfg *Config) HealthChecker {
+	if err := cfg.validate(); err != nil {
 		slog.Error(err.Error())
-		return nil
+		os.Exit(1)
 	}
-	k8sClient, err := kubernetes.NewForConfig(config)
-	if err != nil {
-		slog.Error(err.Error())
-		return nil
+	cfg.authType = strings.ToLower(cfg.authType)
+	cfg.certExpiryPolicy = strings.ToLower(cfg.certExpiryPolicy)
+	// The gRPC storage and the methods without the credentials don't need the sources
+	var source credentialSource
+	if len(credentialKeys[cfg.authType].required) > 0 && !strings.EqualFold(cfg.storage, grpcStorage) {
+		creds, from, err := loadCredentials(cfg, credentialSources(cfg, newKubernetesClient))
+		if err != nil {
+			slog.Error(err.Error())
+			os.Exit(1)
+		}
+		cfg.authCredentials = creds
+		source = from
 	}
-	secret, err := k8sClient.CoreV1().Secrets(namespace).Get(context.TODO(), secretName, metaV1.GetOptions{})
+	checker, err := newChecker(cfg)
 	if err != nil {
 		slog.Error(err.Error())
-		return nil
-	}
-	return secret
-}
-
-func readFromSecret(secret *v1.Secret, key string) string {
-	value := string(secret.Data[key])
-	if value == "" {
-		slog.Error(fmt.Sprintf("Can't read the field '%s' from the secret '%s/%s'", key, secret.Namespace, secret.Name))
 		os.Exit(1)
 	}
-	return value
+	// The wrappers don't provide the certificates, so they are taken from the checker itself
+	var certs *certReloader
+	if provider, ok := checker.(certificatesProvider); ok {
+		certs = provider.certificates()
+	}
+	if _, fromSecret := source.(*secretSource); fromSecret && cfg.watchSecret {
+		checker = watchCredentials(cfg, checker)
+	}
+	if certs != nil {
+		checker = newCertExpiryChecker(checker, certs, cfg)
+	}
+	return checker
 }
 
-func createCassandraClient(host string, port int, user string, password string, tlsEnabled bool, ca string, crt string, key string, verification bool, timeout time.Duration, errorsCount int, datacenter string, keyspace string) *gocql.Session {
-	cluster := gocql.NewCluster(host)
-	cluster.Port = port
-	cluster.Keyspace = keyspace
-	cluster.ConnectTimeout = time.Second * timeout
-	cluster.NumConns = 1
-	if tlsEnabled {
-		if verification {
-			cluster.SslOpts = &gocql.SslOptions{
-				EnableHostVerification: !verification,
-			}
-		} else {
-			cluster.SslOpts = &gocql.SslOptions{
-				CertPath:               crt,
-				CaPath:                 ca,
-				KeyPath:                key,
-				EnableHostVerification: !verification,
-			}
-		}
-	}
-	cluster.Authenticator = gocql.PasswordAuthenticator{
-		Username: user,
-		Password: password,
+// watchCredentials updates the credentials of the checker when the Secret is changed
+func watchCredentials(cfg *Config, checker HealthChecker) HealthChecker {
+	updater, ok := checker.(credentialsUpdater)
+	if !ok {
+		return checker
 	}
-	cluster.PoolConfig.HostSelectionPolicy = gocql.DCAwareRoundRobinPolicy(datacenter)
-	cluster.ProtoVersion = 4
-	cluster.Consistency = gocql.Quorum
-	cluster.DisableInitialHostLookup = true
-	session, err := createSessionWithRetry(cluster, errorsCount, time.Second)
+	k8sClient, err := newKubernetesClient()
 	if err != nil {
-		slog.Error(fmt.Sprintf("Can't create session: %s", err.Error()))
-		os.Exit(1)
+		slog.Error(fmt.Sprintf("Can't watch the secret '%s/%s': %s", cfg.namespace, cfg.authSecretName, err.Error()))
+		return checker
 	}
-	return session
+	watcher := newSecretWatcher(k8sClient, cfg, updater)
+	watcher.start()
+	return &reloadingChecker{HealthChecker: checker, watcher: watcher}
 }
 
-func createHttpClient(user string, password string, tlsEnabled bool, ca string, crt string, key string, verification bool, timeout time.Duration) *HttpClient {
-	client := http.Client{Timeout: timeout * time.Second}
-	if tlsEnabled {
-		tlsConfig := &tls.Config{
-			InsecureSkipVerify: verification,
-		}
-		if !verification {
-			// load tls certificates
-			clientTLSCert, err := tls.LoadX509KeyPair(crt, key)
-			if err != nil {
-				slog.Error(fmt.Sprintf("Error loading certificate and key files: %v", err))
-			}
-			// Configure the client to trust TLS server certs issued by a CA.
-			certPool, err := x509.SystemCertPool()
-			if err != nil {
-				slog.Error(err.Error())
-			}
-			if caCertPEM, err := os.ReadFile(ca); err != nil {
-				slog.Error(err.Error())
-			} else if ok := certPool.AppendCertsFromPEM(caCertPEM); !ok {
-				slog.Error("Invalid cert in CA PEM")
-			}
-			tlsConfig = &tls.Config{
-				RootCAs:            certPool,
-				Certificates:       []tls.Certificate{clientTLSCert},
-				InsecureSkipVerify: verification,
-			}
-		}
-		client.Transport = &http.Transport{
-			IdleConnTimeout: timeout * time.Second,
-			TLSClientConfig: tlsConfig,
-		}
+func newKubernetesClient() (kubernetes.Interface, error) {
+	config, err := ctrl.GetConfig()
+	if err != nil {
+		return nil, err
 	}
-	return &HttpClient{
-		client:   client,
-		user:     user,
-		password: password,
+	return kubernetes.NewForConfig(config)
+}
+
+// createTLSConfig returns the client TLS configuration and the reloader of its certificates,
+// the client certificate is sent even when the verification of the server is disabled
+func createTLSConfig(opts tlsOptions) (*tls.Config, *certReloader) {
+	var source tlsSource = &tlsFiles{caPath: opts.caPath, crtPath: opts.crtPath, keyPath: opts.keyPath}
+	if opts.fromKubernetes() {
+		source = newTLSSecrets(opts, newKubernetesClient)
 	}
+	slog.Info(fmt.Sprintf("TLS is enabled %s, the certificates are read from %s", opts.mode(), source))
+	certs := newSourceReloader(source)
+	certs.start()
+	return certs.tlsConfig(opts), certs
 }
 
 func (s *Server) livenessProbe(w http.ResponseWriter, _ *http.Request) {
@@ -326,8 +212,12 @@ func (s *Server) livenessProbe(w http.ResponseWriter, _ *http.Request) {
 	}
 }
 
-func (s *Server) readinessProbe(w http.ResponseWriter, _ *http.Request) {
-	if isHealth {
+func (s *Server) readinessProbe(w http.ResponseWriter, r *http.Request) {
+	if s.report != nil && wantsJSON(r.URL.Query().Get("verbose"), r.Header.Get("Accept")) {
+		s.writeReport(w)
+		return
+	}
+	if s.state.ready() {
 		w.WriteHeader(http.StatusOK)
 		w.Header().Set("Content-Type", "application/text")
 		_, err := io.WriteString(w, http.StatusText(http.StatusOK))
@@ -340,104 +230,49 @@ func (s *Server) readinessProbe(w http.ResponseWriter, _ *http.Request) {
 	}
 }
 
-func (s *Server) isHealth() bool {
-	if strings.EqualFold(s.storage, cassandra) {
-		return s.cassandraHealth()
+func (s *Server) writeReport(w http.ResponseWriter) {
+	state := s.state.snapshot()
+	healthy := isReady(state.State)
+	report := s.report.snapshot(healthy)
+	report.State = state.State
+	report.LastTransitionTime = state.LastTransitionTime
+	report.Transitions = state.Transitions
+	status := http.StatusOK
+	if !healthy {
+		slog.Error("Readiness probe failed")
+		status = http.StatusInternalServerError
 	}
-	return s.opensearchHealth()
-}
-
-func (s *Server) cassandraHealth() bool {
-	errors := 0
-	for errors < s.errorsCount {
-		if s.cassandra != nil {
-			query := s.cassandra.Query(fmt.Sprintf("SELECT * FROM %s.%s limit 1;", s.keyspace, s.testTable))
-			if query != nil {
-				err := query.Exec()
-				if err != nil {
-					slog.Error("Can't select from table. The error from server: ", "error", err.Error())
-				} else {
-					return true
-				}
-			}
-		}
-		errors += 1
-		slog.Info(fmt.Sprintf("Remaining attempts: %d", s.errorsCount-errors))
-		if errors >= s.errorsCount {
-			return false
-		}
-		slog.Info("Sleep for 5 sec and try again")
-		time.Sleep(5 * time.Second)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		slog.Error("Can't send response")
 	}
-	return false
 }
 
-func (s *Server) opensearchHealth() bool {
-	req, _ := http.NewRequest(http.MethodGet, s.endpoint, http.NoBody)
-	req.SetBasicAuth(s.opensearch.user, s.opensearch.password)
-
-	errors := 0
-	for errors < s.errorsCount {
-		res, err := s.opensearch.client.Do(req)
-		for (err != nil) && (errors < s.errorsCount) {
-			errors += 1
-			slog.Error(fmt.Sprintf("Catch an error: %s, remaining attempts: %d", err.Error(), s.errorsCount-errors))
-			res, err = s.opensearch.client.Do(req)
-		}
-		if err != nil {
-			slog.Error(err.Error())
-			return false
-		}
-		if err := res.Body.Close(); err != nil {
-			slog.Error(fmt.Sprintf("Error closing response body: %s", err.Error()))
-		}
-		// Immediate success check
-		if res.StatusCode == 200 {
-			return true
-		}
-		// If no retries are configured, treat non-200 as failure to avoid infinite loops
-		if s.retryCount == 0 {
-			slog.Info(fmt.Sprintf("Get response code: %d", res.StatusCode))
-			slog.Error("Can't get response from opensearch for a long time")
-			return false
-		}
-
-		retries := 0
-		for retries < s.retryCount {
-			if res.StatusCode == 200 {
-				return true
-			} else {
-				slog.Info(fmt.Sprintf("Get response code: %d", res.StatusCode))
-				if res.StatusCode == http.StatusTooManyRequests {
-					slog.Info("Sleep for 60 sec and try again")
-					time.Sleep(60 * time.Second)
-				} else {
-					slog.Info(fmt.Sprintf("Remaining attempts: %d", s.retryCount-retries))
-				}
-				retries += 1
-				if retries < s.retryCount {
-					res, err = s.opensearch.client.Do(req)
-					if err != nil {
-						slog.Error(err.Error())
-						return false
-					}
-				}
-			}
-		}
-		// If we exhausted retries without success, increment error count
-		errors += 1
-	}
-	return false
+// isHealth runs the check within checkTimeout and updates the readiness state, it returns the result of the check itself
+func (s *Server) isHealth(ctx context.Context) bool {
+	return s.check(ctx).Healthy
 }
 
-func createSessionWithRetry(cluster *gocql.ClusterConfig, maxRetries int, retryDelay time.Duration) (*gocql.Session, error) {
-	for i := 1; i <= maxRetries; i++ {
-		session, err := cluster.CreateSession()
-		if err == nil {
-			return session, nil
-		}
-		slog.Error("Failed to create Cassandra session", "attempt", i, "err", err)
-		time.Sleep(retryDelay)
+// check runs the check within checkTimeout and updates the readiness state.
+// The check which exceeds the deadline fails, the result of the check cancelled by the shutdown is dropped.
+func (s *Server) check(ctx context.Context) Result {
+	checkCtx := ctx
+	if s.checkTimeout > 0 {
+		var cancel context.CancelFunc
+		checkCtx, cancel = context.WithTimeout(ctx, s.checkTimeout)
+		defer cancel()
+	}
+	res := s.checker.Check(checkCtx)
+	if ctx.Err() != nil {
+		return res
+	}
+	now := time.Now()
+	if s.report != nil {
+		s.report.update(res, now)
 	}
-	return nil, fmt.Errorf("failed to create Cassandra session after %d attempts", maxRetries)
+	recordMetrics(res)
+	s.state.observe(res, now)
+	recordReadiness(s.state.ready())
+	return res
 }
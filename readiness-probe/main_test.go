@@ -10,7 +10,6 @@ import (
 	"flag"
 	"fmt"
 	"io"
-	"log/slog"
 	"math/big"
 	"net/http"
 	"net/http/httptest"
@@ -19,21 +18,18 @@ import (
 	"strings"
 	"testing"
 	"time"
-
-	"github.com/gocql/gocql"
-	v1 "k8s.io/api/core/v1"
 )
 
-// runExitTest runs a test that expects the process to exit with error
-func runExitTest(t *testing.T, envVar string, testName string, setupFunc func()) {
+// runExitTest runs a test that expects the process to exit with error and returns its output
+func runExitTest(t *testing.T, envVar string, testName string, setupFunc func()) string {
 	if os.Getenv(envVar) == "1" {
 		setupFunc()
-		return
+		return ""
 	}
 
 	cmd := exec.Command(os.Args[0], "-test.run="+testName)
 	cmd.Env = append(os.Environ(), envVar+"=1")
-	err := cmd.Run()
+	output, err := cmd.CombinedOutput()
 
 	if err == nil {
 		t.Fatal("expected process to exit with error, got nil")
@@ -47,6 +43,7 @@ func runExitTest(t *testing.T, envVar string, testName string, setupFunc func())
 	if exitErr.ExitCode() == 0 {
 		t.Fatal("expected non-zero exit code")
 	}
+	return string(output)
 }
 
 func TestLivenessProbe(t *testing.T) {
@@ -63,10 +60,8 @@ func TestLivenessProbe(t *testing.T) {
 }
 
 func TestReadinessProbeHealthy(t *testing.T) {
-	isHealth = true
-	defer func() { isHealth = false }()
-
 	server := &Server{}
+	server.state.observe(Result{Healthy: true}, time.Now())
 	rec := httptest.NewRecorder()
 	req := httptest.NewRequest(http.MethodGet, "/health", nil)
 
@@ -78,7 +73,6 @@ func TestReadinessProbeHealthy(t *testing.T) {
 }
 
 func TestReadinessProbeUnhealthy(t *testing.T) {
-	isHealth = false
 	server := &Server{}
 	rec := httptest.NewRecorder()
 	req := httptest.NewRequest(http.MethodGet, "/health", nil)
@@ -90,162 +84,6 @@ func TestReadinessProbeUnhealthy(t *testing.T) {
 	}
 }
 
-func TestIsHealth_Routing(t *testing.T) {
-	serverCassandra := &Server{storage: cassandra}
-	serverOpensearch := &Server{storage: "opensearch"}
-
-	if strings.EqualFold(serverCassandra.storage, cassandra) != true {
-		t.Errorf("expected cassandra storage routing")
-	}
-	if strings.EqualFold(serverOpensearch.storage, cassandra) != false {
-		t.Errorf("expected opensearch storage routing")
-	}
-}
-
-func TestIsHealth_Cassandra(t *testing.T) {
-	// Test that isHealth calls cassandraHealth for cassandra storage
-	server := &Server{
-		storage:     cassandra,
-		cassandra:   nil, // Use nil to avoid panic, still tests routing
-		errorsCount: 1,
-		keyspace:    "test",
-		testTable:   "test",
-	}
-	// This will call cassandraHealth with nil session, which returns false
-	result := server.isHealth()
-	if result {
-		t.Error("expected false for cassandra health with nil session")
-	}
-}
-
-func TestIsHealth_Opensearch(t *testing.T) {
-	// Test that isHealth calls opensearchHealth for opensearch storage
-	server := &Server{
-		storage:     "opensearch",
-		opensearch:  &HttpClient{client: http.Client{Timeout: 1 * time.Second}, user: "u", password: "p"},
-		endpoint:    "http://invalid-endpoint", // Will fail but that's ok for coverage
-		errorsCount: 1,
-		retryCount:  0,
-	}
-	defer func() {
-		if r := recover(); r != nil {
-			t.Fatalf("isHealth panicked: %v", r)
-		}
-	}()
-	// This will call opensearchHealth which will fail to connect, but that's expected
-	result := server.isHealth()
-	// Result doesn't matter for coverage, we just want to ensure the opensearch path is taken
-	_ = result
-}
-
-func TestCassandraHealth_NilSession(t *testing.T) {
-	server := &Server{
-		cassandra:   nil,
-		errorsCount: 1,
-		keyspace:    "test",
-		testTable:   "test",
-	}
-	if server.cassandraHealth() {
-		t.Error("expected false for nil cassandra session")
-	}
-}
-
-// Mock implementations for testing
-type mockCassandraSession struct {
-	queryResult error
-}
-
-func (m *mockCassandraSession) Query(stmt string, values ...interface{}) Query {
-	return &mockQuery{result: m.queryResult}
-}
-
-func (m *mockCassandraSession) Close() {}
-
-type mockQuery struct {
-	result error
-}
-
-func (m *mockQuery) Exec() error {
-	return m.result
-}
-
-func TestCassandraHealth_Success(t *testing.T) {
-	server := &Server{
-		cassandra:   &mockCassandraSession{queryResult: nil}, // Mock successful query
-		errorsCount: 3,
-		keyspace:    "test",
-		testTable:   "test",
-	}
-	if !server.cassandraHealth() {
-		t.Error("expected true for successful cassandra query")
-	}
-}
-
-func TestCassandraHealth_QueryFailure(t *testing.T) {
-	server := &Server{
-		cassandra:   &mockCassandraSession{queryResult: fmt.Errorf("query failed")}, // Mock failed query
-		errorsCount: 1,
-		keyspace:    "test",
-		testTable:   "test",
-	}
-	if server.cassandraHealth() {
-		t.Error("expected false for failed cassandra query")
-	}
-}
-
-func TestOpensearchHealth_NilClient(t *testing.T) {
-	server := &Server{
-		opensearch: nil,
-		endpoint:   "http://test",
-	}
-
-	defer func() {
-		if r := recover(); r == nil {
-			t.Fatalf("expected panic from nil opensearch.client")
-		}
-	}()
-
-	server.opensearchHealth()
-}
-
-func TestCreateHttpClient_NoTLS(t *testing.T) {
-	client := createHttpClient("user", "pass", false, "", "", "", false, 5*time.Second)
-	if client == nil {
-		t.Fatal("expected non-nil HttpClient")
-	}
-}
-
-func TestReadFromSecret_ValidValue(t *testing.T) {
-	secret := &v1.Secret{
-		Data: map[string][]byte{
-			v1.BasicAuthUsernameKey: []byte("testuser"),
-		},
-	}
-	result := readFromSecret(secret, v1.BasicAuthUsernameKey)
-	if result != "testuser" {
-		t.Errorf("expected 'testuser', got '%s'", result)
-	}
-}
-
-func TestReadFromSecret_ValidPassword(t *testing.T) {
-	secret := &v1.Secret{
-		Data: map[string][]byte{
-			v1.BasicAuthPasswordKey: []byte("testpass"),
-		},
-	}
-	result := readFromSecret(secret, v1.BasicAuthPasswordKey)
-	if result != "testpass" {
-		t.Errorf("expected 'testpass', got '%s'", result)
-	}
-}
-
-func TestReadFromSecret_EmptyValue(t *testing.T) {
-	runExitTest(t, "BE_CRASHER", "TestReadFromSecret_EmptyValue", func() {
-		secret := &v1.Secret{Data: map[string][]byte{}}
-		readFromSecret(secret, v1.BasicAuthUsernameKey)
-	})
-}
-
 func TestFlagParsing_ServicePort(t *testing.T) {
 	originalArgs := os.Args
 	defer func() { os.Args = originalArgs }()
@@ -296,10 +134,8 @@ func TestLivenessProbe_BodyAndHeader(t *testing.T) {
 }
 
 func TestReadinessProbe_Healthy_BodyAndHeader(t *testing.T) {
-	isHealth = true
-	defer func() { isHealth = false }()
-
 	server := &Server{}
+	server.state.observe(Result{Healthy: true}, time.Now())
 	rec := httptest.NewRecorder()
 	req := httptest.NewRequest(http.MethodGet, "/health", nil)
 
@@ -313,97 +149,6 @@ func TestReadinessProbe_Healthy_BodyAndHeader(t *testing.T) {
 	}
 }
 
-func TestCreateHttpClient_TLS_Verification(t *testing.T) {
-	hc := createHttpClient("u", "p", true, "", "", "", true, 1*time.Second)
-	if hc == nil {
-		t.Fatal("expected non-nil HttpClient")
-	}
-	tr, ok := hc.client.Transport.(*http.Transport)
-	if !ok {
-		t.Fatalf("expected http.Transport, got %T", hc.client.Transport)
-	}
-	if tr.TLSClientConfig == nil || tr.TLSClientConfig.InsecureSkipVerify != true {
-		t.Fatalf("expected TLS InsecureSkipVerify true")
-	}
-	if hc.user != "u" || hc.password != "p" {
-		t.Fatalf("expected user/pass to be set")
-	}
-}
-
-type errRoundTripper struct{}
-
-func (e errRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
-	return nil, fmt.Errorf("boom")
-}
-
-func TestOpensearchHealth_Success(t *testing.T) {
-	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write([]byte("ok"))
-	}))
-	defer srv.Close()
-
-	s := &Server{
-		opensearch:  &HttpClient{client: http.Client{Timeout: 1 * time.Second}, user: "u", password: "p"},
-		endpoint:    srv.URL,
-		errorsCount: 1,
-		retryCount:  1,
-	}
-
-	if !s.opensearchHealth() {
-		t.Fatal("expected true from opensearchHealth")
-	}
-}
-
-func TestOpensearchHealth_RetryThenSuccess(t *testing.T) {
-	count := 0
-	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if count < 2 {
-			w.WriteHeader(http.StatusInternalServerError)
-			count++
-		} else {
-			w.WriteHeader(http.StatusOK)
-		}
-	}))
-	defer srv.Close()
-
-	s := &Server{
-		opensearch:  &HttpClient{client: http.Client{Timeout: 1 * time.Second}, user: "u", password: "p"},
-		endpoint:    srv.URL,
-		errorsCount: 1,
-		retryCount:  5,
-	}
-
-	if !s.opensearchHealth() {
-		t.Fatal("expected true after retries from opensearchHealth")
-	}
-}
-
-func TestOpensearchHealth_ClientError(t *testing.T) {
-	client := http.Client{Transport: errRoundTripper{}}
-	s := &Server{
-		opensearch:  &HttpClient{client: client, user: "u", password: "p"},
-		endpoint:    "http://example",
-		errorsCount: 1,
-		retryCount:  1,
-	}
-
-	if s.opensearchHealth() {
-		t.Fatal("expected false when http client returns error")
-	}
-}
-
-func TestCreateSessionWithRetry_Failure(t *testing.T) {
-	cluster := &gocql.ClusterConfig{Hosts: []string{"127.0.0.1"}, ConnectTimeout: 1 * time.Millisecond}
-	_, err := createSessionWithRetry(cluster, 1, 1*time.Millisecond)
-	if err == nil {
-		t.Fatal("expected error when creating session fails")
-	}
-	if !strings.Contains(err.Error(), "failed to create Cassandra session after 1 attempts") {
-		t.Fatalf("unexpected error message: %v", err)
-	}
-}
-
 func generateSelfSignedCert(t *testing.T) (string, string) {
 	priv, err := rsa.GenerateKey(rand.Reader, 2048)
 	if err != nil {
@@ -459,165 +204,6 @@ func generateSelfSignedCert(t *testing.T) (string, string) {
 	return crtFile.Name(), keyFile.Name()
 }
 
-func TestCreateHttpClient_InvalidFiles_Logs(t *testing.T) {
-	old := slog.Default()
-	defer slog.SetDefault(old)
-	var buf bytes.Buffer
-	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
-
-	crt, key := generateSelfSignedCert(t)
-	caFile, err := os.CreateTemp(t.TempDir(), "ca-*.pem")
-	if err != nil {
-		t.Fatalf("create temp ca: %v", err)
-	}
-	// write invalid CA
-	if _, err := caFile.Write([]byte("not a pem")); err != nil {
-		t.Fatalf("write ca: %v", err)
-	}
-	if err := caFile.Close(); err != nil {
-		t.Fatalf("failed to close ca file: %v", err)
-	}
-
-	hc := createHttpClient("u", "p", true, caFile.Name(), crt, key, false, 1*time.Second)
-	if hc == nil {
-		t.Fatal("expected non-nil HttpClient")
-	}
-
-	log := buf.String()
-	if !strings.Contains(log, "Invalid cert in CA PEM") {
-		t.Fatalf("expected invalid CA pem log, got: %s", log)
-	}
-}
-
-func TestCreateHttpClient_LoadCertError_Logs(t *testing.T) {
-	old := slog.Default()
-	defer slog.SetDefault(old)
-	var buf bytes.Buffer
-	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
-
-	// Provide invalid cert/key paths
-	hc := createHttpClient("u", "p", true, "", "nonexistent.crt", "nonexistent.key", false, 1*time.Second)
-	if hc == nil {
-		t.Fatal("expected non-nil HttpClient")
-	}
-
-	log := buf.String()
-	if !strings.Contains(log, "Error loading certificate and key files") {
-		t.Fatalf("expected load certificate error log, got: %s", log)
-	}
-}
-
-func TestCreateCassandraClient_ExitOnSessionFailure(t *testing.T) {
-	runExitTest(t, "BE_CRASHER_CREATE_CASS", "TestCreateCassandraClient_ExitOnSessionFailure", func() {
-		// this should call os.Exit(1) on failure
-		createCassandraClient("127.0.0.1", 0, "", "", false, "", "", "", false, 1*time.Second, 1, "dc", "ks")
-	})
-}
-
-func TestReadSecret_ExitOnMissingConfig(t *testing.T) {
-	old := slog.Default()
-	defer slog.SetDefault(old)
-	var buf bytes.Buffer
-	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
-
-	_ = readSecret("ns", "name")
-	log := buf.String()
-	if log == "" {
-		t.Fatalf("expected logs when readSecret fails, got empty logs")
-	}
-}
-
-func TestCreateHttpClient_TLS_Success(t *testing.T) {
-	crt, key := generateSelfSignedCert(t)
-	// use same cert as CA
-	caFile, err := os.CreateTemp(t.TempDir(), "ca-*.pem")
-	if err != nil {
-		t.Fatalf("create temp ca: %v", err)
-	}
-	certBytes, err := os.ReadFile(crt)
-	if err != nil {
-		t.Fatalf("read crt: %v", err)
-	}
-	if _, err := caFile.Write(certBytes); err != nil {
-		t.Fatalf("write ca: %v", err)
-	}
-	if err := caFile.Close(); err != nil {
-		t.Fatalf("failed to close ca file: %v", err)
-	}
-
-	hc := createHttpClient("u", "p", true, caFile.Name(), crt, key, false, 1*time.Second)
-	if hc == nil {
-		t.Fatal("expected non-nil HttpClient")
-	}
-	tr, ok := hc.client.Transport.(*http.Transport)
-	if !ok {
-		t.Fatalf("expected http.Transport, got %T", hc.client.Transport)
-	}
-	if tr.TLSClientConfig == nil {
-		t.Fatalf("expected TLSClientConfig to be set")
-	}
-	if len(tr.TLSClientConfig.Certificates) != 1 {
-		t.Fatalf("expected one client certificate, got %d", len(tr.TLSClientConfig.Certificates))
-	}
-	if tr.TLSClientConfig.RootCAs == nil {
-		t.Fatalf("expected RootCAs to be set")
-	}
-}
-
-func TestOpensearchHealth_TooManyRequests_ReturnFalse(t *testing.T) {
-	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusTooManyRequests)
-		_, _ = w.Write([]byte("throttle"))
-	}))
-	defer srv.Close()
-
-	s := &Server{
-		opensearch:  &HttpClient{client: http.Client{Timeout: 1 * time.Second}, user: "u", password: "p"},
-		endpoint:    srv.URL,
-		errorsCount: 1,
-		retryCount:  0,
-	}
-
-	if s.opensearchHealth() {
-		t.Fatal("expected false for 429 when no retries")
-	}
-}
-
-// custom RoundTripper that returns a response whose Body.Close returns an error
-type closeErrReadCloser struct{ rc io.ReadCloser }
-
-func (c closeErrReadCloser) Read(p []byte) (int, error) { return c.rc.Read(p) }
-func (c closeErrReadCloser) Close() error               { return fmt.Errorf("closeboom") }
-
-type respRoundTripper struct{}
-
-func (r respRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
-	body := closeErrReadCloser{rc: io.NopCloser(strings.NewReader("ok"))}
-	return &http.Response{StatusCode: 200, Body: body}, nil
-}
-
-func TestOpensearchHealth_CloseBodyError(t *testing.T) {
-	old := slog.Default()
-	defer slog.SetDefault(old)
-	var buf bytes.Buffer
-	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
-
-	client := http.Client{Transport: respRoundTripper{}}
-	s := &Server{
-		opensearch:  &HttpClient{client: client, user: "u", password: "p"},
-		endpoint:    "http://example",
-		errorsCount: 1,
-		retryCount:  1,
-	}
-
-	if !s.opensearchHealth() {
-		t.Fatal("expected true even if close returns error and status 200")
-	}
-	if !strings.Contains(buf.String(), "Error closing response body") && !strings.Contains(buf.String(), "closeboom") {
-		t.Fatalf("expected close error to be logged, got logs: %s", buf.String())
-	}
-}
-
 func TestInitServer_MissingHost_Exit(t *testing.T) {
 	runExitTest(t, "BE_CRASHER_INIT_HOST", "TestInitServer_MissingHost_Exit", func() {
 		os.Args = []string{"test"}
@@ -625,11 +211,30 @@ func TestInitServer_MissingHost_Exit(t *testing.T) {
 	})
 }
 
-func TestInitServer_MissingAuthSecretName_Exit(t *testing.T) {
-	runExitTest(t, "BE_CRASHER_INIT_AUTH", "TestInitServer_MissingAuthSecretName_Exit", func() {
+func TestInitServer_MissingCredentials_Exit(t *testing.T) {
+	output := runExitTest(t, "BE_CRASHER_INIT_AUTH", "TestInitServer_MissingCredentials_Exit", func() {
 		os.Args = []string{"test", "-host=127.0.0.1"}
 		initServer()
 	})
+	if !strings.Contains(output, "no credentials for -authType=basic are found") {
+		t.Fatalf("expected missing credentials error, got: %s", output)
+	}
+}
+
+func TestInitServer_NoAuthWithoutSecret(t *testing.T) {
+	if os.Getenv("BE_INIT_NO_AUTH") == "1" {
+		os.Args = []string{"test", "-host=127.0.0.1", "-storage=opensearch", "-authType=none"}
+		if server := initServer(); server.checker == nil {
+			t.Fatal("expected the checker to be created")
+		}
+		return
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=TestInitServer_NoAuthWithoutSecret")
+	cmd.Env = append(os.Environ(), "BE_INIT_NO_AUTH=1")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("expected the server to be created without the Secret, got %v: %s", err, output)
+	}
 }
 
 func TestInitServer_TLSMissingFiles_Exit(t *testing.T) {
@@ -686,176 +291,6 @@ func TestInitServer_TLSFiles(t *testing.T) {
 	})
 }
 
-func TestCreateCassandraClient_TLS_InsecureSkipVerify(t *testing.T) {
-	// This should test the insecureSkipVerify path in createCassandraClient
-	runExitTest(t, "BE_CRASHER_CASS_TLS", "TestCreateCassandraClient_TLS_InsecureSkipVerify", func() {
-		createCassandraClient("127.0.0.1", 9042, "u", "p", true, "", "", "", true, 1*time.Second, 1, "dc", "ks")
-	})
-}
-
-func TestCreateCassandraClient_TLS_WithCerts(t *testing.T) {
-	// This should test the TLS with certs path in createCassandraClient
-	runExitTest(t, "BE_CRASHER_CASS_TLS_CERTS", "TestCreateCassandraClient_TLS_WithCerts", func() {
-		crt, key := generateSelfSignedCert(t)
-		caFile, err := os.CreateTemp(t.TempDir(), "ca-*.pem")
-		if err != nil {
-			t.Fatalf("create temp ca: %v", err)
-		}
-		certBytes, err := os.ReadFile(crt)
-		if err != nil {
-			t.Fatalf("read crt: %v", err)
-		}
-		if _, err := caFile.Write(certBytes); err != nil {
-			t.Fatalf("write ca: %v", err)
-		}
-		if err := caFile.Close(); err != nil {
-			t.Fatalf("failed to close ca file: %v", err)
-		}
-
-		createCassandraClient("127.0.0.1", 9042, "u", "p", true, caFile.Name(), crt, key, false, 1*time.Second, 1, "dc", "ks")
-	})
-}
-
-func TestOpensearchHealth_RetryLoop(t *testing.T) {
-	count := 0
-	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		count++
-		if count <= 2 {
-			w.WriteHeader(http.StatusInternalServerError)
-		} else {
-			w.WriteHeader(http.StatusOK)
-		}
-	}))
-	defer srv.Close()
-
-	s := &Server{
-		opensearch:  &HttpClient{client: http.Client{Timeout: 1 * time.Second}, user: "u", password: "p"},
-		endpoint:    srv.URL,
-		errorsCount: 3,
-		retryCount:  2,
-	}
-
-	if !s.opensearchHealth() {
-		t.Fatal("expected true after retries")
-	}
-}
-
-func TestOpensearchHealth_MaxRetries(t *testing.T) {
-	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusInternalServerError)
-	}))
-	defer srv.Close()
-
-	s := &Server{
-		opensearch:  &HttpClient{client: http.Client{Timeout: 1 * time.Second}, user: "u", password: "p"},
-		endpoint:    srv.URL,
-		errorsCount: 1,
-		retryCount:  2,
-	}
-
-	if s.opensearchHealth() {
-		t.Fatal("expected false when max retries exceeded")
-	}
-}
-
-func TestCassandraHealth_RetrySuccess(t *testing.T) {
-	// Since we can't easily mock dynamic behavior, we'll test with a successful mock
-	server := &Server{
-		cassandra:   &mockCassandraSession{queryResult: nil}, // Always succeed
-		errorsCount: 3,
-		keyspace:    "test",
-		testTable:   "test",
-	}
-	if !server.cassandraHealth() {
-		t.Error("expected true for successful cassandra query")
-	}
-}
-
-func TestCassandraHealth_MaxErrors(t *testing.T) {
-	server := &Server{
-		cassandra:   &mockCassandraSession{queryResult: fmt.Errorf("persistent failure")},
-		errorsCount: 2,
-		keyspace:    "test",
-		testTable:   "test",
-	}
-	if server.cassandraHealth() {
-		t.Error("expected false when max errors reached")
-	}
-}
-
-// Test additional opensearchHealth paths
-func TestOpensearchHealth_429_WithSleep(t *testing.T) {
-	// This would test the 429 handling with sleep, but sleep takes too long for tests
-	// Instead, we'll just ensure the path exists by checking existing tests
-	t.Skip("Skipping slow test")
-}
-
-func TestCreateHttpClient_TLS_SystemCertPoolSuccess(t *testing.T) {
-	crt, key := generateSelfSignedCert(t)
-	caFile, err := os.CreateTemp(t.TempDir(), "ca-*.pem")
-	if err != nil {
-		t.Fatalf("create temp ca: %v", err)
-	}
-	certBytes, err := os.ReadFile(crt)
-	if err != nil {
-		t.Fatalf("read crt: %v", err)
-	}
-	if _, err := caFile.Write(certBytes); err != nil {
-		t.Fatalf("write ca: %v", err)
-	}
-	if err := caFile.Close(); err != nil {
-		t.Fatalf("failed to close ca file: %v", err)
-	}
-
-	hc := createHttpClient("u", "p", true, caFile.Name(), crt, key, false, 1*time.Second)
-	if hc == nil {
-		t.Fatal("expected non-nil HttpClient")
-	}
-	// Check that cert pool was loaded successfully
-	tr, ok := hc.client.Transport.(*http.Transport)
-	if !ok {
-		t.Fatalf("expected http.Transport, got %T", hc.client.Transport)
-	}
-	if tr.TLSClientConfig.RootCAs == nil {
-		t.Fatalf("expected RootCAs to be set")
-	}
-}
-
-func TestCreateHttpClient_TLS_InsecureSkipVerify(t *testing.T) {
-	hc := createHttpClient("u", "p", true, "", "", "", true, 1*time.Second)
-	if hc == nil {
-		t.Fatal("expected non-nil HttpClient")
-	}
-	tr, ok := hc.client.Transport.(*http.Transport)
-	if !ok {
-		t.Fatalf("expected http.Transport, got %T", hc.client.Transport)
-	}
-	if tr.TLSClientConfig == nil || !tr.TLSClientConfig.InsecureSkipVerify {
-		t.Fatalf("expected TLS InsecureSkipVerify true")
-	}
-}
-
-func TestCreateHttpClient_TLS_SystemCertPoolError(t *testing.T) {
-	old := slog.Default()
-	defer slog.SetDefault(old)
-	var buf bytes.Buffer
-	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
-
-	// This will test the x509.SystemCertPool() error path
-	// We can't easily trigger this, but we can test with invalid CA file
-	crt, key := generateSelfSignedCert(t)
-	hc := createHttpClient("u", "p", true, "/nonexistent/ca.pem", crt, key, false, 1*time.Second)
-	if hc == nil {
-		t.Fatal("expected non-nil HttpClient")
-	}
-
-	log := buf.String()
-	if !strings.Contains(log, "Error") {
-		t.Logf("Log output: %s", log)
-		// The error logging happens in the function, but we can't easily trigger SystemCertPool error
-	}
-}
-
 func TestLivenessProbe_WriteError(t *testing.T) {
 	server := &Server{}
 	req := httptest.NewRequest(http.MethodGet, "/livez", nil)
@@ -868,10 +303,8 @@ func TestLivenessProbe_WriteError(t *testing.T) {
 }
 
 func TestReadinessProbe_WriteError(t *testing.T) {
-	isHealth = true
-	defer func() { isHealth = false }()
-
 	server := &Server{}
+	server.state.observe(Result{Healthy: true}, time.Now())
 	req := httptest.NewRequest(http.MethodGet, "/health", nil)
 
 	rec := &errorResponseRecorder{ResponseRecorder: *httptest.NewRecorder()}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const metricsNamespace = "readiness_probe"
+
+// Error classes used as the values of the "reason" label
+const (
+	reasonTimeout     = "timeout"
+	reasonAuth        = "auth"
+	reasonTLS         = "tls"
+	reasonConnection  = "connection"
+	reasonUnavailable = "unavailable"
+	reasonOther       = "other"
+)
+
+var (
+	metricsRegistry = prometheus.NewRegistry()
+
+	checkDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "check_duration_seconds",
+		Help:      "The duration of the storage health check including all attempts.",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"check", "backend"})
+
+	checkSuccesses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "check_successes_total",
+		Help:      "The number of successful storage health checks.",
+	}, []string{"check", "backend"})
+
+	checkFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "check_failures_total",
+		Help:      "The number of failed storage health checks by the class of the error.",
+	}, []string{"check", "backend", "reason"})
+
+	readyState = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "ready",
+		Help:      "The current readiness state, 1 if the storage is ready and 0 otherwise.",
+	})
+
+	certificateExpiryDays = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "certificate_expiry_days",
+		Help:      "The number of days before the TLS certificate expires, negative for the expired certificate.",
+	}, []string{"check", "backend", "kind", "name"})
+
+	throttledRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "throttled_requests_total",
+		Help:      "The number of backoffs after HTTP 429 Too Many Requests responses from the storage.",
+	}, []string{"backend"})
+)
+
+// certificateSeries contains the label values of the certificate expiry series recorded by each check,
+// so the series of the certificates which are gone after the reload are deleted
+var (
+	certificateSeriesMu sync.Mutex
+	certificateSeries   = map[[2]string]map[[2]string]bool{}
+)
+
+func init() {
+	metricsRegistry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		checkDuration,
+		checkSuccesses,
+		checkFailures,
+		readyState,
+		certificateExpiryDays,
+		throttledRequests,
+	)
+}
+
+func metricsHandler() http.Handler {
+	return promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{})
+}
+
+// recordMetrics records the result of the check and of all its sub-checks
+func recordMetrics(res Result) {
+	if len(res.Checks) == 0 {
+		recordCheckMetrics(res)
+	}
+	for _, sub := range res.Checks {
+		recordCheckMetrics(sub)
+	}
+}
+
+func recordCheckMetrics(res Result) {
+	name := res.Name
+	if name == "" {
+		name = "default"
+	}
+	checkDuration.WithLabelValues(name, res.Backend).Observe(res.Latency.Seconds())
+	if res.Healthy {
+		checkSuccesses.WithLabelValues(name, res.Backend).Inc()
+	} else {
+		checkFailures.WithLabelValues(name, res.Backend, classifyError(res.Err)).Inc()
+	}
+	recordCertificateExpiry(name, res.Backend, res.Certificates)
+}
+
+// recordCertificateExpiry sets the expiry of the current certificates of the check and deletes the series of the previous ones
+func recordCertificateExpiry(name string, backend string, certs []certificateExpiry) {
+	certificateSeriesMu.Lock()
+	defer certificateSeriesMu.Unlock()
+	check := [2]string{name, backend}
+	current := map[[2]string]bool{}
+	for _, cert := range certs {
+		certificateExpiryDays.WithLabelValues(name, backend, cert.Kind, cert.Name).Set(time.Until(cert.NotAfter).Hours() / 24)
+		current[[2]string{cert.Kind, cert.Name}] = true
+	}
+	for series := range certificateSeries[check] {
+		if !current[series] {
+			certificateExpiryDays.DeleteLabelValues(name, backend, series[0], series[1])
+		}
+	}
+	certificateSeries[check] = current
+}
+
+func recordReadiness(healthy bool) {
+	if healthy {
+		readyState.Set(1)
+	} else {
+		readyState.Set(0)
+	}
+}
+
+// httpStatusError is returned when the storage responds with the unexpected HTTP status code
+type httpStatusError struct {
+	code int
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("unexpected response code: %d", e.code)
+}
+
+// classifyError returns the class of the error for the "reason" metrics label
+func classifyError(err error) string {
+	if err == nil {
+		return reasonOther
+	}
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		if statusErr.code == http.StatusUnauthorized || statusErr.code == http.StatusForbidden {
+			return reasonAuth
+		}
+		return fmt.Sprintf("http_%d", statusErr.code)
+	}
+	if s, ok := status.FromError(err); ok && s.Code() != codes.Unknown {
+		switch s.Code() {
+		case codes.DeadlineExceeded:
+			return reasonTimeout
+		case codes.Unauthenticated, codes.PermissionDenied:
+			return reasonAuth
+		case codes.Unavailable:
+			if strings.Contains(s.Message(), "tls:") || strings.Contains(s.Message(), "x509:") {
+				return reasonTLS
+			}
+			return reasonUnavailable
+		}
+		return strings.ToLower(s.Code().String())
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, gocql.ErrTimeoutNoResponse) {
+		return reasonTimeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return reasonTimeout
+	}
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidCert x509.CertificateInvalidError
+	var recordErr tls.RecordHeaderError
+	var verificationErr *tls.CertificateVerificationError
+	if errors.As(err, &unknownAuthority) || errors.As(err, &hostnameErr) || errors.As(err, &invalidCert) ||
+		errors.As(err, &recordErr) || errors.As(err, &verificationErr) {
+		return reasonTLS
+	}
+	var requestErr gocql.RequestError
+	if errors.As(err, &requestErr) && (requestErr.Code() == gocql.ErrCodeCredentials || requestErr.Code() == gocql.ErrCodeUnauthorized) {
+		return reasonAuth
+	}
+	var unavailableErr *gocql.RequestErrUnavailable
+	if errors.As(err, &unavailableErr) || errors.Is(err, gocql.ErrNoConnections) || errors.Is(err, gocql.ErrUnavailable) {
+		return reasonUnavailable
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return reasonConnection
+	}
+	return reasonOther
+}
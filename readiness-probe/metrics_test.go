@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{&httpStatusError{code: http.StatusServiceUnavailable}, "http_503"},
+		{&httpStatusError{code: http.StatusUnauthorized}, reasonAuth},
+		{fmt.Errorf("wrapped: %w", &httpStatusError{code: http.StatusForbidden}), reasonAuth},
+		{context.DeadlineExceeded, reasonTimeout},
+		{gocql.ErrTimeoutNoResponse, reasonTimeout},
+		{&net.OpError{Op: "dial", Err: errors.New("connection refused")}, reasonConnection},
+		{x509.UnknownAuthorityError{}, reasonTLS},
+		{gocql.ErrNoConnections, reasonUnavailable},
+		{status.Error(codes.DeadlineExceeded, "deadline"), reasonTimeout},
+		{status.Error(codes.Unauthenticated, "no token"), reasonAuth},
+		{status.Error(codes.Unavailable, "connection error: desc = \"transport: authentication handshake failed: tls: bad certificate\""), reasonTLS},
+		{status.Error(codes.Unavailable, "connection refused"), reasonUnavailable},
+		{status.Error(codes.NotFound, "unknown service"), "notfound"},
+		{errors.New("something"), reasonOther},
+	}
+	for _, tt := range tests {
+		if got := classifyError(tt.err); got != tt.want {
+			t.Errorf("classifyError(%v) = %s, want %s", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRecordMetrics(t *testing.T) {
+	success := testutil.ToFloat64(checkSuccesses.WithLabelValues("main", cassandra))
+	failures := testutil.ToFloat64(checkFailures.WithLabelValues("archive", opensearch, "http_503"))
+
+	recordMetrics(Result{Backend: composite, Checks: []Result{
+		{Name: "main", Healthy: true, Backend: cassandra, Latency: 10 * time.Millisecond},
+		{Name: "archive", Healthy: false, Backend: opensearch, Err: &httpStatusError{code: http.StatusServiceUnavailable}},
+	}})
+
+	if got := testutil.ToFloat64(checkSuccesses.WithLabelValues("main", cassandra)); got != success+1 {
+		t.Errorf("expected success counter %v, got %v", success+1, got)
+	}
+	if got := testutil.ToFloat64(checkFailures.WithLabelValues("archive", opensearch, "http_503")); got != failures+1 {
+		t.Errorf("expected failures counter %v, got %v", failures+1, got)
+	}
+}
+
+func TestIsHealth_RecordsReadiness(t *testing.T) {
+	server := &Server{checker: &stubChecker{result: Result{Healthy: true, Backend: grpcStorage}}}
+	server.isHealth(context.Background())
+	if got := testutil.ToFloat64(readyState); got != 1 {
+		t.Errorf("expected ready gauge 1, got %v", got)
+	}
+	server.checker = &stubChecker{result: Result{Healthy: false, Backend: grpcStorage}}
+	server.isHealth(context.Background())
+	if got := testutil.ToFloat64(readyState); got != 0 {
+		t.Errorf("expected ready gauge 0, got %v", got)
+	}
+}
+
+func TestOpensearchHealth_TooManyRequests_Classified(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	s := &opensearchChecker{
+		client:      &HttpClient{client: http.Client{Timeout: 1 * time.Second}},
+		endpoints:   []string{srv.URL},
+		errorsCount: 1,
+		retryCount:  0,
+	}
+	res := s.Check(context.Background())
+	if res.Healthy || classifyError(res.Err) != "http_429" {
+		t.Fatalf("expected 429 failure, got %+v", res)
+	}
+}
+
+func TestMetricsHandler(t *testing.T) {
+	recordReadiness(true)
+	rec := httptest.NewRecorder()
+	metricsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(rec.Result().Body)
+	for _, name := range []string{"readiness_probe_ready 1", "go_goroutines"} {
+		if !strings.Contains(string(body), name) {
+			t.Errorf("expected metric '%s' in response", name)
+		}
+	}
+}
@@ -0,0 +1,476 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const opensearch string = "opensearch"
+
+type HttpClient struct {
+	client http.Client
+	// mu guards the credentials which are swapped when the Secret is changed
+	mu       sync.RWMutex
+	user     string
+	password string
+	// auth adds the credentials to the requests, the basic authentication is used without it
+	auth requestAuthenticator
+	// certs reloads the client certificate and the CA bundle of the TLS connections
+	certs *certReloader
+}
+
+// authenticate adds the credentials to the request
+func (c *HttpClient) authenticate(req *http.Request, body []byte) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.auth == nil {
+		req.SetBasicAuth(c.user, c.password)
+		return nil
+	}
+	return c.auth.authenticate(req, body)
+}
+
+// setAuth swaps the credentials for the next requests
+func (c *HttpClient) setAuth(user string, password string, auth requestAuthenticator) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.user = user
+	c.password = password
+	c.auth = auth
+}
+
+type opensearchChecker struct {
+	// backend is the storage name in the results, the same checks are used for Elasticsearch
+	backend   string
+	client    *HttpClient
+	endpoints []string
+	// active is the index of the endpoint which answered the last request
+	active      int
+	errorsCount int
+	retryCount  int
+	retry       backoff
+
+	healthIndices       string
+	minClusterStatus    string
+	maxUnassignedShards int
+	maxPendingTasks     int
+
+	verifyIndices   bool
+	useAliases      bool
+	indexPrefix     string
+	indexDateLayout string
+
+	writeCheck         bool
+	writeCheckIndex    string
+	writeIndexTimeout  time.Duration
+	writeReadTimeout   time.Duration
+	writeDeleteTimeout time.Duration
+
+	// headers are added to every request, for example the Elasticsearch compatibility headers
+	headers http.Header
+	// newAuth creates the authenticator of the configured method with the updated credentials
+	newAuth func(creds authCredentials) (requestAuthenticator, error)
+}
+
+func init() {
+	registerChecker(opensearch, newOpensearchChecker)
+}
+
+func newOpensearchChecker(cfg *Config) (HealthChecker, error) {
+	minStatus := strings.ToLower(cfg.minClusterStatus)
+	if minStatus != "green" && minStatus != "yellow" {
+		return nil, fmt.Errorf("invalid -minClusterStatus '%s', possible values: green, yellow", cfg.minClusterStatus)
+	}
+	auth, err := newRequestAuthenticator(cfg)
+	if err != nil {
+		return nil, err
+	}
+	client := createHttpClient(cfg.user, cfg.password, cfg.tlsEnabled, cfg.tlsOptions(), time.Duration(cfg.timeout))
+	client.auth = auth
+	return &opensearchChecker{
+		backend:             opensearch,
+		client:              client,
+		endpoints:           cfg.endpoints(),
+		errorsCount:         cfg.errorsCount,
+		retryCount:          cfg.retryCount,
+		retry:               cfg.retryBackoff(),
+		healthIndices:       cfg.healthIndices,
+		minClusterStatus:    minStatus,
+		maxUnassignedShards: cfg.maxUnassignedShards,
+		maxPendingTasks:     cfg.maxPendingTasks,
+		verifyIndices:       cfg.verifyIndices,
+		useAliases:          cfg.useAliases,
+		indexPrefix:         cfg.indexPrefix,
+		indexDateLayout:     cfg.indexDateLayout,
+		writeCheck:          cfg.writeCheck,
+		writeCheckIndex:     cfg.writeCheckIndex,
+		writeIndexTimeout:   time.Duration(cfg.writeIndexTimeout) * time.Second,
+		writeReadTimeout:    time.Duration(cfg.writeReadTimeout) * time.Second,
+		writeDeleteTimeout:  time.Duration(cfg.writeDeleteTimeout) * time.Second,
+		newAuth: func(creds authCredentials) (requestAuthenticator, error) {
+			authCfg := *cfg
+			authCfg.authCredentials = creds
+			return newRequestAuthenticator(&authCfg)
+		},
+	}, nil
+}
+
+// updateCredentials swaps the credentials of the client, the running requests keep the previous ones
+func (o *opensearchChecker) updateCredentials(creds authCredentials) error {
+	if o.newAuth == nil {
+		return fmt.Errorf("%s checker can't update the credentials", o.backendName())
+	}
+	auth, err := o.newAuth(creds)
+	if err != nil {
+		return err
+	}
+	o.client.setAuth(creds.user, creds.password, auth)
+	return nil
+}
+
+func (o *opensearchChecker) Check(ctx context.Context) Result {
+	start := time.Now()
+	attempts, err := o.health(ctx)
+	if err == nil && o.verifyIndices {
+		err = o.verifyJaegerIndices(ctx, start.UTC())
+	}
+	var steps map[string]time.Duration
+	if err == nil && o.writeCheck {
+		steps, err = o.writeCanary(ctx)
+	}
+	res := Result{
+		Healthy:  err == nil,
+		Backend:  o.backendName(),
+		Endpoint: o.activeEndpoint(),
+		Attempts: attempts,
+		Latency:  time.Since(start),
+		Err:      err,
+		Steps:    steps,
+	}
+	var retryErr *retryAfterError
+	if errors.As(err, &retryErr) {
+		res.RetryAfter = retryErr.delay
+	}
+	return res
+}
+
+func (o *opensearchChecker) backendName() string {
+	if o.backend == "" {
+		return opensearch
+	}
+	return o.backend
+}
+
+func (o *opensearchChecker) certificates() *certReloader {
+	if o.client == nil {
+		return nil
+	}
+	return o.client.certs
+}
+
+func (o *opensearchChecker) Close() {
+	if o.client != nil {
+		o.client.client.CloseIdleConnections()
+		if o.client.certs != nil {
+			o.client.certs.close()
+		}
+	}
+}
+
+func createHttpClient(user string, password string, tlsEnabled bool, tlsOpts tlsOptions, timeout time.Duration) *HttpClient {
+	hc := &HttpClient{
+		client:   http.Client{Timeout: timeout * time.Second},
+		user:     user,
+		password: password,
+	}
+	if tlsEnabled {
+		tlsConfig, certs := createTLSConfig(tlsOpts)
+		hc.client.Transport = &http.Transport{
+			IdleConnTimeout: timeout * time.Second,
+			TLSClientConfig: tlsConfig,
+			DialTLSContext:  dialTLS(&net.Dialer{Timeout: timeout * time.Second}, tlsConfig),
+		}
+		// The kept connections use the previous certificates
+		certs.onChange(hc.client.CloseIdleConnections)
+		hc.certs = certs
+	}
+	return hc
+}
+
+// dialTLS returns the dial function of the transport which verifies the dialed host of every connection
+func dialTLS(dialer *net.Dialer, config *tls.Config) func(ctx context.Context, network string, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network string, addr string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		conn, err := dialer.DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		tlsConn := tls.Client(conn, tlsConfigFor(config, host))
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		return tlsConn, nil
+	}
+}
+
+// clusterHealth contains the checked fields of the _cluster/health response
+type clusterHealth struct {
+	ClusterName          string `json:"cluster_name"`
+	Status               string `json:"status"`
+	TimedOut             bool   `json:"timed_out"`
+	UnassignedShards     int    `json:"unassigned_shards"`
+	NumberOfPendingTasks int    `json:"number_of_pending_tasks"`
+}
+
+var clusterStatusLevels = map[string]int{"red": 0, "yellow": 1, "green": 2}
+
+// healthPath returns the _cluster/health path, scoped to the indices if they are set
+func (o *opensearchChecker) healthPath() string {
+	path := "/_cluster/health"
+	if o.healthIndices != "" {
+		path += "/" + o.healthIndices
+	}
+	return path
+}
+
+// evaluate checks the _cluster/health response against the configured thresholds
+func (o *opensearchChecker) evaluate(body []byte) error {
+	var health clusterHealth
+	if err := json.Unmarshal(body, &health); err != nil {
+		return fmt.Errorf("can't parse cluster health response: %w", err)
+	}
+	level, ok := clusterStatusLevels[health.Status]
+	if !ok {
+		return fmt.Errorf("unknown cluster status '%s'", health.Status)
+	}
+	if level < clusterStatusLevels[o.minClusterStatus] {
+		return fmt.Errorf("cluster '%s' has status %s, required at least %s", health.ClusterName, health.Status, o.minClusterStatus)
+	}
+	if health.TimedOut {
+		return fmt.Errorf("cluster '%s' health request timed out", health.ClusterName)
+	}
+	if o.maxUnassignedShards >= 0 && health.UnassignedShards > o.maxUnassignedShards {
+		return fmt.Errorf("cluster '%s' has %d unassigned shards, allowed %d", health.ClusterName, health.UnassignedShards, o.maxUnassignedShards)
+	}
+	if o.maxPendingTasks >= 0 && health.NumberOfPendingTasks > o.maxPendingTasks {
+		return fmt.Errorf("cluster '%s' has %d pending tasks, allowed %d", health.ClusterName, health.NumberOfPendingTasks, o.maxPendingTasks)
+	}
+	return nil
+}
+
+type httpResponse struct {
+	statusCode int
+	body       []byte
+	// retryAfter is the Retry-After header of the response
+	retryAfter string
+}
+
+// get sends the GET request with the storage credentials and reads the response body
+func (o *opensearchChecker) get(ctx context.Context, path string) (*httpResponse, error) {
+	return o.request(ctx, http.MethodGet, path, nil)
+}
+
+// activeEndpoint returns the endpoint which answered the last request
+func (o *opensearchChecker) activeEndpoint() string {
+	if len(o.endpoints) == 0 {
+		return ""
+	}
+	return o.endpoints[o.active]
+}
+
+// failoverStatus checks if the response code means that the node can't serve the request and another node should be tried
+func failoverStatus(code int) bool {
+	return code == http.StatusBadGateway || code == http.StatusServiceUnavailable || code == http.StatusGatewayTimeout
+}
+
+// request sends the request to the path of the active endpoint. When the endpoint is not reachable or unavailable,
+// the request is sent to the next endpoints in turn and the endpoint which answered becomes active.
+func (o *opensearchChecker) request(ctx context.Context, method string, path string, body []byte) (*httpResponse, error) {
+	if len(o.endpoints) == 0 {
+		return nil, fmt.Errorf("no endpoints are configured")
+	}
+	var res *httpResponse
+	var err error
+	for i := range o.endpoints {
+		index := (o.active + i) % len(o.endpoints)
+		res, err = o.requestURL(ctx, method, strings.TrimRight(o.endpoints[index], "/")+path, body)
+		if err == nil && !failoverStatus(res.statusCode) {
+			if index != o.active {
+				slog.Info(fmt.Sprintf("Switched to the endpoint %s", o.endpoints[index]))
+				o.active = index
+			}
+			return res, nil
+		}
+		if ctx.Err() != nil || len(o.endpoints) == 1 {
+			break
+		}
+		if err != nil {
+			slog.Error(fmt.Sprintf("The endpoint %s failed: %s", o.endpoints[index], err.Error()))
+		} else {
+			slog.Error(fmt.Sprintf("The endpoint %s failed with the response code %d", o.endpoints[index], res.statusCode))
+		}
+	}
+	return res, err
+}
+
+// requestURL sends the request with the JSON body and the storage credentials and reads the response body
+func (o *opensearchChecker) requestURL(ctx context.Context, method string, url string, body []byte) (*httpResponse, error) {
+	var reader io.Reader = http.NoBody
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for name, values := range o.headers {
+		if name == "Content-Type" && body == nil {
+			continue
+		}
+		req.Header[name] = values
+	}
+	if err := o.client.authenticate(req, body); err != nil {
+		return nil, err
+	}
+	return o.send(req)
+}
+
+// send sends the request and reads the response body
+func (o *opensearchChecker) send(req *http.Request) (*httpResponse, error) {
+	res, err := o.client.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(res.Body)
+	if closeErr := res.Body.Close(); closeErr != nil {
+		slog.Error(fmt.Sprintf("Error closing response body: %s", closeErr.Error()))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &httpResponse{statusCode: res.StatusCode, body: body, retryAfter: res.Header.Get("Retry-After")}, nil
+}
+
+// retryDelay returns the delay before the next attempt after the given number of failures,
+// the Retry-After header of the throttled or unavailable storage has priority over the backoff.
+// False is returned when the storage asks to wait longer than -maxRetryDelay or than the deadline of the check,
+// then the attempts are stopped and the next check waits for the storage.
+func (o *opensearchChecker) retryDelay(ctx context.Context, res *httpResponse, failures int) (time.Duration, bool) {
+	if res != nil && (res.statusCode == http.StatusTooManyRequests || res.statusCode == http.StatusServiceUnavailable) {
+		if delay, ok := retryAfter(res.retryAfter, time.Now()); ok {
+			if o.retry.max > 0 && delay > o.retry.max {
+				return delay, false
+			}
+			if deadline, ok := ctx.Deadline(); ok && delay > time.Until(deadline) {
+				return delay, false
+			}
+			return delay, true
+		}
+	}
+	return o.retry.delay(failures), true
+}
+
+// health returns the number of sent requests and the last error, nil error means the storage is healthy
+func (o *opensearchChecker) health(ctx context.Context) (int, error) {
+	res, attempts, err := o.getWithRetries(ctx, o.healthPath())
+	if err != nil {
+		return attempts, err
+	}
+	return attempts, o.evaluate(res.body)
+}
+
+// getWithRetries sends the GET request until the successful response with the -errors and -retries attempts,
+// it returns the successful response, the number of sent requests and the last error
+func (o *opensearchChecker) getWithRetries(ctx context.Context, path string) (*httpResponse, int, error) {
+	attempts := 0
+	do := func() (*httpResponse, error) {
+		attempts += 1
+		return o.request(ctx, http.MethodGet, path, nil)
+	}
+
+	errors := 0
+	for errors < o.errorsCount {
+		res, err := do()
+		for (err != nil) && (errors < o.errorsCount) && ctx.Err() == nil {
+			errors += 1
+			slog.Error(fmt.Sprintf("Catch an error: %s, remaining attempts: %d", err.Error(), o.errorsCount-errors))
+			if waitErr := sleepContext(ctx, o.retry.delay(errors)); waitErr != nil {
+				return nil, attempts, interrupted(waitErr, err)
+			}
+			res, err = do()
+		}
+		if err != nil {
+			slog.Error(err.Error())
+			return nil, attempts, err
+		}
+		// Immediate success check
+		if res.statusCode == 200 {
+			return res, attempts, nil
+		}
+		// If no retries are configured, treat non-200 as failure to avoid infinite loops
+		if o.retryCount == 0 {
+			slog.Info(fmt.Sprintf("Get response code: %d", res.statusCode))
+			slog.Error(fmt.Sprintf("Can't get response from %s for a long time", o.backendName()))
+			return nil, attempts, &httpStatusError{code: res.statusCode}
+		}
+
+		retries := 0
+		for retries < o.retryCount {
+			if res.statusCode == 200 {
+				return res, attempts, nil
+			} else {
+				slog.Info(fmt.Sprintf("Get response code: %d", res.statusCode))
+				if res.statusCode == http.StatusTooManyRequests {
+					throttledRequests.WithLabelValues(o.backendName()).Inc()
+				}
+				retries += 1
+				if retries < o.retryCount {
+					delay, ok := o.retryDelay(ctx, res, retries)
+					if !ok {
+						return nil, attempts, &retryAfterError{err: &httpStatusError{code: res.statusCode}, delay: delay}
+					}
+					slog.Info(fmt.Sprintf("Remaining attempts: %d, sleep for %s and try again", o.retryCount-retries, delay.Round(time.Millisecond)))
+					if waitErr := sleepContext(ctx, delay); waitErr != nil {
+						return nil, attempts, interrupted(waitErr, &httpStatusError{code: res.statusCode})
+					}
+					res, err = do()
+					if err != nil {
+						slog.Error(err.Error())
+						return nil, attempts, err
+					}
+				}
+			}
+		}
+		// If we exhausted retries without success, increment error count
+		errors += 1
+		if errors >= o.errorsCount {
+			return nil, attempts, &httpStatusError{code: res.statusCode}
+		}
+		delay, ok := o.retryDelay(ctx, res, errors)
+		if !ok {
+			return nil, attempts, &retryAfterError{err: &httpStatusError{code: res.statusCode}, delay: delay}
+		}
+		if waitErr := sleepContext(ctx, delay); waitErr != nil {
+			return nil, attempts, interrupted(waitErr, &httpStatusError{code: res.statusCode})
+		}
+	}
+	return nil, attempts, fmt.Errorf("no attempts are allowed by -errors=%d", o.errorsCount)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Jaeger indices which must be writable for the collector
+var jaegerWriteIndices = []string{"jaeger-span", "jaeger-service"}
+
+// Index settings which make the index read-only
+var writeBlockSettings = []string{"index.blocks.write", "index.blocks.read_only", "index.blocks.read_only_allow_delete"}
+
+// aliasesResponse is the response of the _alias API: index name -> aliases
+type aliasesResponse map[string]struct {
+	Aliases map[string]struct {
+		IsWriteIndex *bool `json:"is_write_index"`
+	} `json:"aliases"`
+}
+
+// settingsResponse is the response of the _settings API with flat settings: index name -> settings
+type settingsResponse map[string]struct {
+	Settings map[string]string `json:"settings"`
+}
+
+// indexMissingError is returned when the verified index doesn't exist
+type indexMissingError struct {
+	index string
+}
+
+func (e *indexMissingError) Error() string {
+	return fmt.Sprintf("index '%s' is missing", e.index)
+}
+
+// indexName applies the Jaeger index prefix in the same way as Jaeger does
+func (o *opensearchChecker) indexName(name string) string {
+	if o.indexPrefix == "" {
+		return name
+	}
+	if strings.HasSuffix(o.indexPrefix, "-") {
+		return o.indexPrefix + name
+	}
+	return o.indexPrefix + "-" + name
+}
+
+// verifyJaegerIndices checks that the write aliases of spans and services exist and are writable,
+// or that the daily indices are writable if they already exist.
+// Jaeger creates the daily index on the first write of the day, so the missing index of today isn't an error,
+// otherwise the collectors wouldn't be ready to create it after midnight and after the installation.
+func (o *opensearchChecker) verifyJaegerIndices(ctx context.Context, now time.Time) error {
+	for _, name := range jaegerWriteIndices {
+		if o.useAliases {
+			index, err := o.writeIndexOfAlias(ctx, o.indexName(name+"-write"))
+			if err == nil {
+				err = o.verifyWritable(ctx, index)
+			}
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		err := o.verifyWritable(ctx, o.indexName(name+"-"+now.Format(o.indexDateLayout)))
+		var missingErr *indexMissingError
+		if err != nil && !errors.As(err, &missingErr) {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeIndexOfAlias returns the index which receives the writes through the alias
+func (o *opensearchChecker) writeIndexOfAlias(ctx context.Context, alias string) (string, error) {
+	res, err := o.get(ctx, "/_alias/"+url.PathEscape(alias))
+	if err != nil {
+		return "", fmt.Errorf("can't read write alias '%s': %w", alias, err)
+	}
+	if res.statusCode == http.StatusNotFound {
+		return "", fmt.Errorf("write alias '%s' is missing", alias)
+	}
+	if res.statusCode != http.StatusOK {
+		return "", fmt.Errorf("can't read write alias '%s': %w", alias, &httpStatusError{code: res.statusCode})
+	}
+	var aliases aliasesResponse
+	if err := json.Unmarshal(res.body, &aliases); err != nil {
+		return "", fmt.Errorf("can't parse write alias '%s' response: %w", alias, err)
+	}
+	var indices []string
+	for index, entry := range aliases {
+		a, ok := entry.Aliases[alias]
+		if !ok {
+			continue
+		}
+		if a.IsWriteIndex != nil && *a.IsWriteIndex {
+			return index, nil
+		}
+		indices = append(indices, index)
+	}
+	// Without is_write_index the alias is writable only if it points to the single index
+	if len(indices) == 1 {
+		return indices[0], nil
+	}
+	sort.Strings(indices)
+	if len(indices) == 0 {
+		return "", fmt.Errorf("write alias '%s' is missing", alias)
+	}
+	return "", fmt.Errorf("write alias '%s' points to several indices %s without the write index", alias, strings.Join(indices, ", "))
+}
+
+// verifyWritable checks that the index exists and has no write blocks
+func (o *opensearchChecker) verifyWritable(ctx context.Context, index string) error {
+	res, err := o.get(ctx, "/"+url.PathEscape(index)+"/_settings?flat_settings=true")
+	if err != nil {
+		return fmt.Errorf("can't read settings of index '%s': %w", index, err)
+	}
+	if res.statusCode == http.StatusNotFound {
+		return &indexMissingError{index: index}
+	}
+	if res.statusCode != http.StatusOK {
+		return fmt.Errorf("can't read settings of index '%s': %w", index, &httpStatusError{code: res.statusCode})
+	}
+	var settings settingsResponse
+	if err := json.Unmarshal(res.body, &settings); err != nil {
+		return fmt.Errorf("can't parse settings of index '%s': %w", index, err)
+	}
+	entry, ok := settings[index]
+	if !ok {
+		return &indexMissingError{index: index}
+	}
+	for _, setting := range writeBlockSettings {
+		if entry.Settings[setting] == "true" {
+			return fmt.Errorf("index '%s' is read-only: %s=true", index, setting)
+		}
+	}
+	return nil
+}
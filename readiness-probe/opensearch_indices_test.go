@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newIndicesServer emulates the cluster with the given aliases (alias -> response body) and index settings (index -> settings)
+func newIndicesServer(t *testing.T, aliases map[string]string, settings map[string]string) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/_cluster/health":
+			_, _ = w.Write([]byte(greenClusterHealth))
+		case strings.HasPrefix(r.URL.Path, "/_alias/"):
+			body, ok := aliases[strings.TrimPrefix(r.URL.Path, "/_alias/")]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write([]byte(body))
+		case strings.HasSuffix(r.URL.Path, "/_settings"):
+			index := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/"), "/_settings")
+			s, ok := settings[index]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = fmt.Fprintf(w, `{%q:{"settings":%s}}`, index, s)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newIndicesChecker(t *testing.T, endpoint string, useAliases bool) *opensearchChecker {
+	return newTestOpensearchChecker(t, "-host="+endpoint, "-errors=1", "-verifyIndices=true", fmt.Sprintf("-useAliases=%t", useAliases))
+}
+
+func TestVerifyJaegerIndices_Aliases(t *testing.T) {
+	srv := newIndicesServer(t, map[string]string{
+		"jaeger-span-write":    `{"jaeger-span-000001":{"aliases":{"jaeger-span-write":{"is_write_index":false}}},"jaeger-span-000002":{"aliases":{"jaeger-span-write":{"is_write_index":true}}}}`,
+		"jaeger-service-write": `{"jaeger-service-000001":{"aliases":{"jaeger-service-write":{}}}}`,
+	}, map[string]string{
+		"jaeger-span-000002":    `{"index.number_of_shards":"1"}`,
+		"jaeger-service-000001": `{"index.blocks.write":"false"}`,
+	})
+	checker := newIndicesChecker(t, srv.URL, true)
+	if res := checker.Check(context.Background()); !res.Healthy {
+		t.Fatalf("expected healthy result, got %v", res.Err)
+	}
+}
+
+func TestVerifyJaegerIndices_MissingAlias(t *testing.T) {
+	srv := newIndicesServer(t, map[string]string{
+		"jaeger-span-write": `{"jaeger-span-000001":{"aliases":{"jaeger-span-write":{}}}}`,
+	}, map[string]string{
+		"jaeger-span-000001": `{}`,
+	})
+	res := newIndicesChecker(t, srv.URL, true).Check(context.Background())
+	if res.Healthy || res.Err == nil || res.Err.Error() != "write alias 'jaeger-service-write' is missing" {
+		t.Fatalf("expected missing alias error, got %v", res.Err)
+	}
+}
+
+func TestVerifyJaegerIndices_ReadOnlyIndex(t *testing.T) {
+	srv := newIndicesServer(t, map[string]string{
+		"jaeger-span-write": `{"jaeger-span-000001":{"aliases":{"jaeger-span-write":{}}}}`,
+	}, map[string]string{
+		"jaeger-span-000001": `{"index.blocks.read_only_allow_delete":"true"}`,
+	})
+	err := newIndicesChecker(t, srv.URL, true).verifyJaegerIndices(context.Background(), time.Now())
+	if err == nil || !strings.Contains(err.Error(), "index 'jaeger-span-000001' is read-only: index.blocks.read_only_allow_delete=true") {
+		t.Fatalf("expected read-only error, got %v", err)
+	}
+}
+
+func TestVerifyJaegerIndices_AliasWithoutWriteIndex(t *testing.T) {
+	srv := newIndicesServer(t, map[string]string{
+		"jaeger-span-write": `{"jaeger-span-000001":{"aliases":{"jaeger-span-write":{}}},"jaeger-span-000002":{"aliases":{"jaeger-span-write":{}}}}`,
+	}, nil)
+	err := newIndicesChecker(t, srv.URL, true).verifyJaegerIndices(context.Background(), time.Now())
+	if err == nil || !strings.Contains(err.Error(), "points to several indices jaeger-span-000001, jaeger-span-000002") {
+		t.Fatalf("expected several indices error, got %v", err)
+	}
+}
+
+func TestVerifyJaegerIndices_DailyIndices(t *testing.T) {
+	now := time.Date(2026, 3, 4, 10, 0, 0, 0, time.UTC)
+	srv := newIndicesServer(t, nil, map[string]string{
+		"tracing-jaeger-span-2026-03-04":    `{}`,
+		"tracing-jaeger-service-2026-03-04": `{}`,
+	})
+	checker := newIndicesChecker(t, srv.URL, false)
+	checker.indexPrefix = "tracing"
+	if err := checker.verifyJaegerIndices(context.Background(), now); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The index of the next day is created by Jaeger on the first write
+	if err := checker.verifyJaegerIndices(context.Background(), now.Add(24*time.Hour)); err != nil {
+		t.Fatalf("expected the missing daily index to be ready, got %v", err)
+	}
+}
+
+func TestVerifyJaegerIndices_ReadOnlyDailyIndex(t *testing.T) {
+	now := time.Date(2026, 3, 4, 10, 0, 0, 0, time.UTC)
+	srv := newIndicesServer(t, nil, map[string]string{
+		"jaeger-service-2026-03-04": `{"index.blocks.write":"true"}`,
+	})
+	err := newIndicesChecker(t, srv.URL, false).verifyJaegerIndices(context.Background(), now)
+	if err == nil || err.Error() != "index 'jaeger-service-2026-03-04' is read-only: index.blocks.write=true" {
+		t.Fatalf("expected read-only error, got %v", err)
+	}
+}
+
+func TestVerifyJaegerIndices_AliasToMissingIndex(t *testing.T) {
+	srv := newIndicesServer(t, map[string]string{
+		"jaeger-span-write": `{"jaeger-span-000001":{"aliases":{"jaeger-span-write":{}}}}`,
+	}, nil)
+	err := newIndicesChecker(t, srv.URL, true).verifyJaegerIndices(context.Background(), time.Now())
+	if err == nil || err.Error() != "index 'jaeger-span-000001' is missing" {
+		t.Fatalf("expected missing index error, got %v", err)
+	}
+}
+
+func TestIndexName(t *testing.T) {
+	tests := []struct {
+		prefix string
+		want   string
+	}{
+		{"", "jaeger-span-write"},
+		{"prod", "prod-jaeger-span-write"},
+		{"prod-", "prod-jaeger-span-write"},
+	}
+	for _, tt := range tests {
+		o := &opensearchChecker{indexPrefix: tt.prefix}
+		if got := o.indexName("jaeger-span-write"); got != tt.want {
+			t.Errorf("indexName with prefix '%s' = %s, want %s", tt.prefix, got, tt.want)
+		}
+	}
+}
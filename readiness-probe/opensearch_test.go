@@ -0,0 +1,629 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+const greenClusterHealth = `{"cluster_name":"opensearch","status":"green","timed_out":false,"unassigned_shards":0,"number_of_pending_tasks":0}`
+
+// newTestOpensearchChecker creates the checker by the constructor with the flag defaults changed by the given flags
+func newTestOpensearchChecker(t *testing.T, args ...string) *opensearchChecker {
+	t.Helper()
+	checker, err := newOpensearchChecker(newTestConfig(t, args...))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return checker.(*opensearchChecker)
+}
+
+func TestOpensearchHealth_NilClient(t *testing.T) {
+	s := &opensearchChecker{
+		client:      nil,
+		endpoints:   []string{"http://test"},
+		errorsCount: 1,
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatalf("expected panic from nil opensearch.client")
+		}
+	}()
+
+	s.Check(context.Background())
+}
+
+func TestCreateHttpClient_NoTLS(t *testing.T) {
+	client := createHttpClient("user", "pass", false, tlsOptions{}, 5*time.Second)
+	if client == nil {
+		t.Fatal("expected non-nil HttpClient")
+	}
+}
+
+func TestCreateHttpClient_TLS_Verification(t *testing.T) {
+	hc := createHttpClient("u", "p", true, tlsOptions{insecureSkipVerify: true}, 1*time.Second)
+	if hc == nil {
+		t.Fatal("expected non-nil HttpClient")
+	}
+	tr, ok := hc.client.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("expected http.Transport, got %T", hc.client.Transport)
+	}
+	if tr.TLSClientConfig == nil || tr.TLSClientConfig.InsecureSkipVerify != true {
+		t.Fatalf("expected TLS InsecureSkipVerify true")
+	}
+	if hc.user != "u" || hc.password != "p" {
+		t.Fatalf("expected user/pass to be set")
+	}
+}
+
+type errRoundTripper struct{}
+
+func (e errRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return nil, fmt.Errorf("boom")
+}
+
+func TestOpensearchHealth_Success(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(greenClusterHealth))
+	}))
+	defer srv.Close()
+
+	s := &opensearchChecker{
+		client:      &HttpClient{client: http.Client{Timeout: 1 * time.Second}, user: "u", password: "p"},
+		endpoints:   []string{srv.URL},
+		errorsCount: 1,
+		retryCount:  1,
+	}
+
+	if !s.Check(context.Background()).Healthy {
+		t.Fatal("expected true from opensearchHealth")
+	}
+}
+
+func TestOpensearchHealth_RetryThenSuccess(t *testing.T) {
+	count := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if count < 2 {
+			w.WriteHeader(http.StatusInternalServerError)
+			count++
+		} else {
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte(greenClusterHealth))
+		}
+	}))
+	defer srv.Close()
+
+	s := &opensearchChecker{
+		client:      &HttpClient{client: http.Client{Timeout: 1 * time.Second}, user: "u", password: "p"},
+		endpoints:   []string{srv.URL},
+		errorsCount: 1,
+		retryCount:  5,
+	}
+
+	if !s.Check(context.Background()).Healthy {
+		t.Fatal("expected true after retries from opensearchHealth")
+	}
+}
+
+func TestOpensearchHealth_ClientError(t *testing.T) {
+	client := http.Client{Transport: errRoundTripper{}}
+	s := &opensearchChecker{
+		client:      &HttpClient{client: client, user: "u", password: "p"},
+		endpoints:   []string{"http://example"},
+		errorsCount: 1,
+		retryCount:  1,
+	}
+
+	if s.Check(context.Background()).Healthy {
+		t.Fatal("expected false when http client returns error")
+	}
+}
+
+func TestCreateHttpClient_InvalidFiles_Logs(t *testing.T) {
+	old := slog.Default()
+	defer slog.SetDefault(old)
+	var buf bytes.Buffer
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	crt, key := generateSelfSignedCert(t)
+	caFile, err := os.CreateTemp(t.TempDir(), "ca-*.pem")
+	if err != nil {
+		t.Fatalf("create temp ca: %v", err)
+	}
+	// write invalid CA
+	if _, err := caFile.Write([]byte("not a pem")); err != nil {
+		t.Fatalf("write ca: %v", err)
+	}
+	if err := caFile.Close(); err != nil {
+		t.Fatalf("failed to close ca file: %v", err)
+	}
+
+	hc := createHttpClient("u", "p", true, tlsOptions{caPath: caFile.Name(), crtPath: crt, keyPath: key}, 1*time.Second)
+	if hc == nil {
+		t.Fatal("expected non-nil HttpClient")
+	}
+
+	log := buf.String()
+	if !strings.Contains(log, "Invalid cert in CA PEM") {
+		t.Fatalf("expected invalid CA pem log, got: %s", log)
+	}
+}
+
+func TestCreateHttpClient_LoadCertError_Logs(t *testing.T) {
+	old := slog.Default()
+	defer slog.SetDefault(old)
+	var buf bytes.Buffer
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	// Provide invalid cert/key paths
+	hc := createHttpClient("u", "p", true, tlsOptions{crtPath: "nonexistent.crt", keyPath: "nonexistent.key"}, 1*time.Second)
+	if hc == nil {
+		t.Fatal("expected non-nil HttpClient")
+	}
+
+	log := buf.String()
+	if !strings.Contains(log, "Error loading certificate and key files") {
+		t.Fatalf("expected load certificate error log, got: %s", log)
+	}
+}
+
+func TestCreateHttpClient_TLS_Success(t *testing.T) {
+	crt, key := generateSelfSignedCert(t)
+	// use same cert as CA
+	caFile, err := os.CreateTemp(t.TempDir(), "ca-*.pem")
+	if err != nil {
+		t.Fatalf("create temp ca: %v", err)
+	}
+	certBytes, err := os.ReadFile(crt)
+	if err != nil {
+		t.Fatalf("read crt: %v", err)
+	}
+	if _, err := caFile.Write(certBytes); err != nil {
+		t.Fatalf("write ca: %v", err)
+	}
+	if err := caFile.Close(); err != nil {
+		t.Fatalf("failed to close ca file: %v", err)
+	}
+
+	hc := createHttpClient("u", "p", true, tlsOptions{caPath: caFile.Name(), crtPath: crt, keyPath: key}, 1*time.Second)
+	if hc == nil {
+		t.Fatal("expected non-nil HttpClient")
+	}
+	tr, ok := hc.client.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("expected http.Transport, got %T", hc.client.Transport)
+	}
+	if tr.TLSClientConfig == nil {
+		t.Fatalf("expected TLSClientConfig to be set")
+	}
+	cert, err := tr.TLSClientConfig.GetClientCertificate(&tls.CertificateRequestInfo{})
+	if err != nil || len(cert.Certificate) != 1 {
+		t.Fatalf("expected one client certificate, got %v", err)
+	}
+	if hc.certs == nil || hc.certs.roots == nil {
+		t.Fatalf("expected the CA bundle to be loaded")
+	}
+	hc.certs.close()
+}
+
+func TestOpensearchHealth_TooManyRequests_ReturnFalse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte("throttle"))
+	}))
+	defer srv.Close()
+
+	s := &opensearchChecker{
+		client:      &HttpClient{client: http.Client{Timeout: 1 * time.Second}, user: "u", password: "p"},
+		endpoints:   []string{srv.URL},
+		errorsCount: 1,
+		retryCount:  0,
+	}
+
+	if s.Check(context.Background()).Healthy {
+		t.Fatal("expected false for 429 when no retries")
+	}
+}
+
+// custom RoundTripper that returns a response whose Body.Close returns an error
+type closeErrReadCloser struct{ rc io.ReadCloser }
+
+func (c closeErrReadCloser) Read(p []byte) (int, error) { return c.rc.Read(p) }
+
+func (c closeErrReadCloser) Close() error { return fmt.Errorf("closeboom") }
+
+type respRoundTripper struct{}
+
+func (r respRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	body := closeErrReadCloser{rc: io.NopCloser(strings.NewReader(greenClusterHealth))}
+	return &http.Response{StatusCode: 200, Body: body}, nil
+}
+
+func TestOpensearchHealth_CloseBodyError(t *testing.T) {
+	old := slog.Default()
+	defer slog.SetDefault(old)
+	var buf bytes.Buffer
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	client := http.Client{Transport: respRoundTripper{}}
+	s := &opensearchChecker{
+		client:      &HttpClient{client: client, user: "u", password: "p"},
+		endpoints:   []string{"http://example"},
+		errorsCount: 1,
+		retryCount:  1,
+	}
+
+	if !s.Check(context.Background()).Healthy {
+		t.Fatal("expected true even if close returns error and status 200")
+	}
+	if !strings.Contains(buf.String(), "Error closing response body") && !strings.Contains(buf.String(), "closeboom") {
+		t.Fatalf("expected close error to be logged, got logs: %s", buf.String())
+	}
+}
+
+func TestOpensearchHealth_RetryLoop(t *testing.T) {
+	count := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count++
+		if count <= 2 {
+			w.WriteHeader(http.StatusInternalServerError)
+		} else {
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte(greenClusterHealth))
+		}
+	}))
+	defer srv.Close()
+
+	s := &opensearchChecker{
+		client:      &HttpClient{client: http.Client{Timeout: 1 * time.Second}, user: "u", password: "p"},
+		endpoints:   []string{srv.URL},
+		errorsCount: 3,
+		retryCount:  2,
+	}
+
+	if !s.Check(context.Background()).Healthy {
+		t.Fatal("expected true after retries")
+	}
+}
+
+func TestOpensearchHealth_MaxRetries(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	s := &opensearchChecker{
+		client:      &HttpClient{client: http.Client{Timeout: 1 * time.Second}, user: "u", password: "p"},
+		endpoints:   []string{srv.URL},
+		errorsCount: 1,
+		retryCount:  2,
+	}
+
+	if s.Check(context.Background()).Healthy {
+		t.Fatal("expected false when max retries exceeded")
+	}
+}
+
+func TestOpensearchHealth_429_WithSleep(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	s := &opensearchChecker{
+		client:      &HttpClient{client: http.Client{Timeout: 1 * time.Second}},
+		endpoints:   []string{srv.URL},
+		errorsCount: 1,
+		retryCount:  2,
+		retry:       backoff{base: time.Hour},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	res := s.Check(ctx)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("expected the sleep to be interrupted by the deadline, took %s", elapsed)
+	}
+	if res.Healthy || !errors.Is(res.Err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline error, got %+v", res)
+	}
+	var statusErr *httpStatusError
+	if !errors.As(res.Err, &statusErr) || statusErr.code != http.StatusTooManyRequests {
+		t.Fatalf("expected the last 429 response in the error, got %v", res.Err)
+	}
+}
+
+func TestOpensearchRetryDelay_Limits(t *testing.T) {
+	res := &httpResponse{statusCode: http.StatusTooManyRequests, retryAfter: "30"}
+	s := &opensearchChecker{retry: backoff{base: time.Second, max: 10 * time.Second}}
+	if got, ok := s.retryDelay(context.Background(), res, 1); ok || got != 30*time.Second {
+		t.Errorf("expected Retry-After above -maxRetryDelay to stop the attempts, got %s, %v", got, ok)
+	}
+	s.retry = backoff{base: time.Second, max: time.Minute}
+	if got, ok := s.retryDelay(context.Background(), res, 1); !ok || got != 30*time.Second {
+		t.Errorf("expected the delay from Retry-After, got %s, %v", got, ok)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if got, ok := s.retryDelay(ctx, res, 1); ok || got != 30*time.Second {
+		t.Errorf("expected Retry-After after the deadline to stop the attempts, got %s, %v", got, ok)
+	}
+}
+
+func TestOpensearchHealth_RetryAfterBeyondLimit(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests += 1
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	s := &opensearchChecker{
+		client:      &HttpClient{client: http.Client{Timeout: 1 * time.Second}},
+		endpoints:   []string{srv.URL},
+		errorsCount: 2,
+		retryCount:  3,
+		retry:       backoff{base: time.Second, max: 10 * time.Second},
+	}
+	res := s.Check(context.Background())
+	if res.Healthy || res.RetryAfter != 30*time.Second || requests != 1 {
+		t.Fatalf("expected the attempts to stop with the Retry-After delay, got %+v after %d requests", res, requests)
+	}
+	if classifyError(res.Err) != "http_503" || !strings.Contains(res.Err.Error(), "the storage asks to retry after 30s") {
+		t.Fatalf("unexpected error: %v", res.Err)
+	}
+}
+
+func TestOpensearchHealth_RetryAfter(t *testing.T) {
+	var requests []time.Time
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, time.Now())
+		if len(requests) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(greenClusterHealth))
+	}))
+	defer srv.Close()
+
+	s := &opensearchChecker{
+		client:      &HttpClient{client: http.Client{Timeout: 1 * time.Second}},
+		endpoints:   []string{srv.URL},
+		errorsCount: 1,
+		retryCount:  2,
+		retry:       backoff{base: time.Hour},
+	}
+	if res := s.Check(context.Background()); !res.Healthy {
+		t.Fatalf("expected success after Retry-After, got %+v", res)
+	}
+	if len(requests) != 2 || requests[1].Sub(requests[0]) < time.Second {
+		t.Fatalf("expected the retry after 1 second, got %v", requests)
+	}
+}
+
+func TestOpensearchHealth_TransportErrorBackoff(t *testing.T) {
+	s := &opensearchChecker{
+		client:      &HttpClient{client: http.Client{Transport: errRoundTripper{}}},
+		endpoints:   []string{"http://example"},
+		errorsCount: 3,
+		retry:       backoff{base: time.Hour},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	res := s.Check(ctx)
+	if res.Attempts != 1 || !errors.Is(res.Err, context.DeadlineExceeded) {
+		t.Fatalf("expected the retry to wait for the backoff, got %+v", res)
+	}
+}
+
+func TestCreateHttpClient_TLS_SystemCertPoolSuccess(t *testing.T) {
+	crt, key := generateSelfSignedCert(t)
+	caFile, err := os.CreateTemp(t.TempDir(), "ca-*.pem")
+	if err != nil {
+		t.Fatalf("create temp ca: %v", err)
+	}
+	certBytes, err := os.ReadFile(crt)
+	if err != nil {
+		t.Fatalf("read crt: %v", err)
+	}
+	if _, err := caFile.Write(certBytes); err != nil {
+		t.Fatalf("write ca: %v", err)
+	}
+	if err := caFile.Close(); err != nil {
+		t.Fatalf("failed to close ca file: %v", err)
+	}
+
+	hc := createHttpClient("u", "p", true, tlsOptions{caPath: caFile.Name(), crtPath: crt, keyPath: key}, 1*time.Second)
+	if hc == nil {
+		t.Fatal("expected non-nil HttpClient")
+	}
+	// Check that cert pool was loaded successfully
+	tr, ok := hc.client.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("expected http.Transport, got %T", hc.client.Transport)
+	}
+	if tr.TLSClientConfig.VerifyConnection == nil || hc.certs == nil || hc.certs.roots == nil {
+		t.Fatalf("expected the CA bundle to be loaded")
+	}
+	hc.certs.close()
+}
+
+func TestCreateHttpClient_TLS_InsecureSkipVerify(t *testing.T) {
+	hc := createHttpClient("u", "p", true, tlsOptions{insecureSkipVerify: true}, 1*time.Second)
+	if hc == nil {
+		t.Fatal("expected non-nil HttpClient")
+	}
+	tr, ok := hc.client.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("expected http.Transport, got %T", hc.client.Transport)
+	}
+	if tr.TLSClientConfig == nil || !tr.TLSClientConfig.InsecureSkipVerify {
+		t.Fatalf("expected TLS InsecureSkipVerify true")
+	}
+}
+
+func TestCreateHttpClient_TLS_SystemCertPoolError(t *testing.T) {
+	old := slog.Default()
+	defer slog.SetDefault(old)
+	var buf bytes.Buffer
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	// This will test the x509.SystemCertPool() error path
+	// We can't easily trigger this, but we can test with invalid CA file
+	crt, key := generateSelfSignedCert(t)
+	hc := createHttpClient("u", "p", true, tlsOptions{caPath: "/nonexistent/ca.pem", crtPath: crt, keyPath: key}, 1*time.Second)
+	if hc == nil {
+		t.Fatal("expected non-nil HttpClient")
+	}
+
+	log := buf.String()
+	if !strings.Contains(log, "Error") {
+		t.Logf("Log output: %s", log)
+		// The error logging happens in the function, but we can't easily trigger SystemCertPool error
+	}
+}
+
+func TestOpensearchHealth_ClusterHealthPath(t *testing.T) {
+	var path string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		_, _ = w.Write([]byte(greenClusterHealth))
+	}))
+	defer srv.Close()
+
+	s := &opensearchChecker{
+		client:        &HttpClient{client: http.Client{Timeout: 1 * time.Second}},
+		endpoints:     []string{srv.URL + "/"},
+		errorsCount:   1,
+		healthIndices: "jaeger-span-*,jaeger-service-*",
+	}
+	if !s.Check(context.Background()).Healthy {
+		t.Fatal("expected healthy cluster")
+	}
+	if path != "/_cluster/health/jaeger-span-*,jaeger-service-*" {
+		t.Fatalf("unexpected request path: %s", path)
+	}
+}
+
+func TestOpensearchEvaluate(t *testing.T) {
+	tests := []struct {
+		name    string
+		checker opensearchChecker
+		body    string
+		wantErr string
+	}{
+		{"green", opensearchChecker{minClusterStatus: "green", maxUnassignedShards: -1, maxPendingTasks: -1}, greenClusterHealth, ""},
+		{"yellow allowed", opensearchChecker{minClusterStatus: "yellow", maxUnassignedShards: -1, maxPendingTasks: -1},
+			`{"cluster_name":"os","status":"yellow","unassigned_shards":5}`, ""},
+		{"yellow required green", opensearchChecker{minClusterStatus: "green", maxUnassignedShards: -1, maxPendingTasks: -1},
+			`{"cluster_name":"os","status":"yellow"}`, "has status yellow, required at least green"},
+		{"red", opensearchChecker{minClusterStatus: "yellow", maxUnassignedShards: -1, maxPendingTasks: -1},
+			`{"cluster_name":"os","status":"red"}`, "has status red"},
+		{"timed out", opensearchChecker{minClusterStatus: "yellow", maxUnassignedShards: -1, maxPendingTasks: -1},
+			`{"cluster_name":"os","status":"green","timed_out":true}`, "timed out"},
+		{"unassigned shards", opensearchChecker{minClusterStatus: "yellow", maxUnassignedShards: 2, maxPendingTasks: -1},
+			`{"cluster_name":"os","status":"yellow","unassigned_shards":3}`, "3 unassigned shards, allowed 2"},
+		{"pending tasks", opensearchChecker{minClusterStatus: "yellow", maxUnassignedShards: -1, maxPendingTasks: 10},
+			`{"cluster_name":"os","status":"green","number_of_pending_tasks":11}`, "11 pending tasks, allowed 10"},
+		{"unknown status", opensearchChecker{minClusterStatus: "yellow"}, `{"status":"blue"}`, "unknown cluster status"},
+		{"invalid body", opensearchChecker{minClusterStatus: "yellow"}, `ok`, "can't parse cluster health response"},
+	}
+	for _, tt := range tests {
+		err := tt.checker.evaluate([]byte(tt.body))
+		if tt.wantErr == "" && err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+		}
+		if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("%s: expected error containing '%s', got %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
+
+func TestOpensearchHealth_RedCluster(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"cluster_name":"os","status":"red"}`))
+	}))
+	defer srv.Close()
+
+	s := &opensearchChecker{
+		client:           &HttpClient{client: http.Client{Timeout: 1 * time.Second}},
+		endpoints:        []string{srv.URL},
+		errorsCount:      1,
+		minClusterStatus: "yellow",
+	}
+	if s.Check(context.Background()).Healthy {
+		t.Fatal("expected red cluster to be not ready")
+	}
+}
+
+func TestNewOpensearchChecker_InvalidMinStatus(t *testing.T) {
+	if _, err := newOpensearchChecker(&Config{hosts: hostList{"http://os"}, minClusterStatus: "red"}); err == nil {
+		t.Fatal("expected error for unsupported minimal cluster status")
+	}
+}
+
+func TestOpensearchFailover(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	requests := 0
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests += 1
+		_, _ = w.Write([]byte(greenClusterHealth))
+	}))
+	defer up.Close()
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+
+	s := &opensearchChecker{
+		client:           &HttpClient{client: http.Client{Timeout: 1 * time.Second}},
+		endpoints:        []string{unreachable.URL, down.URL, up.URL},
+		errorsCount:      1,
+		minClusterStatus: "yellow",
+	}
+	res := s.Check(context.Background())
+	if !res.Healthy || res.Attempts != 1 {
+		t.Fatalf("expected healthy result after failover, got %+v", res)
+	}
+	if res.Endpoint != up.URL {
+		t.Fatalf("expected answered endpoint %s, got %s", up.URL, res.Endpoint)
+	}
+
+	// The endpoint which answered is used first on the next check
+	if res := s.Check(context.Background()); !res.Healthy || res.Endpoint != up.URL || requests != 2 {
+		t.Fatalf("expected the active endpoint to be reused, got %+v after %d requests", res, requests)
+	}
+}
+
+func TestOpensearchFailover_AllDown(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer down.Close()
+
+	s := &opensearchChecker{
+		client:      &HttpClient{client: http.Client{Timeout: 1 * time.Second}},
+		endpoints:   []string{down.URL, down.URL + "/"},
+		errorsCount: 1,
+	}
+	res := s.Check(context.Background())
+	if res.Healthy || classifyError(res.Err) != "http_502" {
+		t.Fatalf("expected 502 failure, got %+v", res)
+	}
+}
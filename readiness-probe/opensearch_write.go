@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// canaryDocument is written by the write check into the probe index
+type canaryDocument struct {
+	Probe     string    `json:"probe"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// openSearchErrorResponse contains the error fields of the failed OpenSearch request
+type openSearchErrorResponse struct {
+	Error struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	} `json:"error"`
+}
+
+// responseError describes the unexpected response with the error type and reason from the body,
+// for example cluster_block_exception when the index is read-only by the disk watermark
+func responseError(res *httpResponse) error {
+	statusErr := &httpStatusError{code: res.statusCode}
+	var body openSearchErrorResponse
+	if err := json.Unmarshal(res.body, &body); err != nil || body.Error.Type == "" {
+		return statusErr
+	}
+	return fmt.Errorf("%s: %s: %w", body.Error.Type, body.Error.Reason, statusErr)
+}
+
+// writeCanary indexes the canary document, reads it back and deletes it, each step has its own timeout.
+// The indexed document is deleted even if the read fails, so the failed checks don't leave the documents behind,
+// unless the check itself is cancelled or reaches its deadline.
+// The latency of the completed steps is returned.
+func (o *opensearchChecker) writeCanary(ctx context.Context) (latency map[string]time.Duration, err error) {
+	hostname, _ := os.Hostname()
+	doc := canaryDocument{Probe: hostname, Timestamp: time.Now().UTC()}
+	id := fmt.Sprintf("%s-%d", hostname, doc.Timestamp.UnixNano())
+	docPath := "/" + url.PathEscape(o.writeCheckIndex) + "/_doc/" + url.PathEscape(id)
+	body, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	latency = map[string]time.Duration{}
+	if err := o.writeStep(ctx, latency, "index", http.MethodPut, docPath, body, o.writeIndexTimeout); err != nil {
+		return latency, err
+	}
+	defer func() {
+		// The delete has its own timeout, so it runs after the timed out read too.
+		// It is stopped with the check, the document left after the shutdown is better than the blocked shutdown.
+		deleteErr := o.writeStep(ctx, latency, "delete", http.MethodDelete, docPath, nil, o.writeDeleteTimeout)
+		if deleteErr == nil {
+			return
+		}
+		if err != nil {
+			slog.Error(deleteErr.Error())
+			return
+		}
+		err = deleteErr
+	}()
+	return latency, o.writeStep(ctx, latency, "read", http.MethodGet, docPath, nil, o.writeReadTimeout)
+}
+
+// writeStep sends the request of the write check step with the timeout and records the latency of the completed step
+func (o *opensearchChecker) writeStep(ctx context.Context, latency map[string]time.Duration, name string, method string, path string, body []byte, timeout time.Duration) error {
+	start := time.Now()
+	stepCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	res, err := o.request(stepCtx, method, path, body)
+	if err != nil {
+		return fmt.Errorf("write check can't %s the canary document in '%s': %w", name, o.writeCheckIndex, err)
+	}
+	if res.statusCode != http.StatusOK && res.statusCode != http.StatusCreated {
+		return fmt.Errorf("write check can't %s the canary document in '%s': %w", name, o.writeCheckIndex, responseError(res))
+	}
+	latency[name] = time.Since(start)
+	slog.Info(fmt.Sprintf("Write check step '%s' in '%s' took %s", name, o.writeCheckIndex, latency[name]))
+	return nil
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// canaryServer stores the documents in memory and fails the requests with the configured method
+type canaryServer struct {
+	mu       sync.Mutex
+	docs     map[string]string
+	methods  []string
+	failWith string
+	status   int
+	body     string
+	delay    time.Duration
+}
+
+func (c *canaryServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if r.URL.Path == "/_cluster/health" {
+		_, _ = w.Write([]byte(greenClusterHealth))
+		return
+	}
+	c.methods = append(c.methods, r.Method)
+	if r.Method == c.failWith {
+		if c.delay > 0 {
+			time.Sleep(c.delay)
+		}
+		w.WriteHeader(c.status)
+		_, _ = w.Write([]byte(c.body))
+		return
+	}
+	switch r.Method {
+	case http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		c.docs[r.URL.Path] = string(body)
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodGet:
+		if _, ok := c.docs[r.URL.Path]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"found":true}`))
+	case http.MethodDelete:
+		delete(c.docs, r.URL.Path)
+	}
+}
+
+func newWriteChecker(t *testing.T, endpoint string) *opensearchChecker {
+	return newTestOpensearchChecker(t, "-host="+endpoint, "-errors=1", "-writeCheck=true")
+}
+
+func TestWriteCanary_Success(t *testing.T) {
+	canary := &canaryServer{docs: map[string]string{}}
+	srv := httptest.NewServer(canary)
+	defer srv.Close()
+
+	res := newWriteChecker(t, srv.URL).Check(context.Background())
+	if !res.Healthy {
+		t.Fatalf("expected healthy result, got %v", res.Err)
+	}
+	if strings.Join(canary.methods, ",") != "PUT,GET,DELETE" {
+		t.Fatalf("unexpected requests: %v", canary.methods)
+	}
+	for _, step := range []string{"index", "read", "delete"} {
+		if _, ok := res.Steps[step]; !ok {
+			t.Errorf("expected latency of step '%s' in result", step)
+		}
+	}
+	if len(canary.docs) != 0 {
+		t.Fatalf("expected canary document to be deleted, got %v", canary.docs)
+	}
+}
+
+func TestWriteCanary_ClusterBlock(t *testing.T) {
+	canary := &canaryServer{
+		docs:     map[string]string{},
+		failWith: http.MethodPut,
+		status:   http.StatusTooManyRequests,
+		body:     `{"error":{"type":"cluster_block_exception","reason":"index [jaeger-readiness-probe] blocked by: [TOO_MANY_REQUESTS/12/disk usage exceeded flood-stage watermark, index has read-only-allow-delete block];"},"status":429}`,
+	}
+	srv := httptest.NewServer(canary)
+	defer srv.Close()
+
+	res := newWriteChecker(t, srv.URL).Check(context.Background())
+	if res.Healthy {
+		t.Fatal("expected write check to fail")
+	}
+	if !strings.Contains(res.Err.Error(), "can't index the canary document in 'jaeger-readiness-probe': cluster_block_exception: index [jaeger-readiness-probe] blocked by") {
+		t.Fatalf("unexpected error: %v", res.Err)
+	}
+	var statusErr *httpStatusError
+	if !errors.As(res.Err, &statusErr) || statusErr.code != http.StatusTooManyRequests {
+		t.Fatalf("expected wrapped HTTP status error, got %v", res.Err)
+	}
+}
+
+func TestWriteCanary_MappingFailure(t *testing.T) {
+	canary := &canaryServer{
+		docs:     map[string]string{},
+		failWith: http.MethodPut,
+		status:   http.StatusBadRequest,
+		body:     `{"error":{"type":"strict_dynamic_mapping_exception","reason":"mapping set to strict, dynamic introduction of [probe] within [_doc] is not allowed"},"status":400}`,
+	}
+	srv := httptest.NewServer(canary)
+	defer srv.Close()
+
+	_, err := newWriteChecker(t, srv.URL).writeCanary(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "strict_dynamic_mapping_exception") {
+		t.Fatalf("expected mapping error, got %v", err)
+	}
+}
+
+func TestWriteCanary_ReadTimeout(t *testing.T) {
+	canary := &canaryServer{
+		docs:     map[string]string{},
+		failWith: http.MethodGet,
+		status:   http.StatusOK,
+		delay:    200 * time.Millisecond,
+	}
+	srv := httptest.NewServer(canary)
+	defer srv.Close()
+
+	checker := newWriteChecker(t, srv.URL)
+	checker.writeReadTimeout = 50 * time.Millisecond
+	latency, err := checker.writeCanary(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "can't read the canary document") || classifyError(err) != reasonTimeout {
+		t.Fatalf("expected read timeout, got %v", err)
+	}
+	canary.mu.Lock()
+	defer canary.mu.Unlock()
+	if strings.Join(canary.methods, ",") != "PUT,GET,DELETE" || len(canary.docs) != 0 {
+		t.Fatalf("expected canary document to be deleted after the read timeout, got requests %v and documents %v", canary.methods, canary.docs)
+	}
+	if _, ok := latency["delete"]; !ok {
+		t.Errorf("expected latency of step 'delete' in result, got %v", latency)
+	}
+}
+
+func TestResponseError_NoBody(t *testing.T) {
+	err := responseError(&httpResponse{statusCode: http.StatusServiceUnavailable})
+	if err.Error() != "unexpected response code: 503" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestWriteCanary_DeleteStoppedWithCheck(t *testing.T) {
+	canary := &canaryServer{
+		docs:     map[string]string{},
+		failWith: http.MethodDelete,
+		status:   http.StatusOK,
+		delay:    time.Second,
+	}
+	srv := httptest.NewServer(canary)
+	defer srv.Close()
+
+	checker := newWriteChecker(t, srv.URL)
+	checker.writeDeleteTimeout = time.Minute
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := checker.writeCanary(ctx)
+	if elapsed := time.Since(start); elapsed > 900*time.Millisecond {
+		t.Fatalf("expected the delete to be stopped with the check, took %s", elapsed)
+	}
+	if err == nil || !strings.Contains(err.Error(), "can't delete the canary document") {
+		t.Fatalf("expected the delete error, got %v", err)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	statusUp       string = "UP"
+	statusDown     string = "DOWN"
+	statusDegraded string = "DEGRADED"
+)
+
+// CheckReport is the JSON representation of the latest check result
+type CheckReport struct {
+	Name                string                 `json:"name,omitempty"`
+	Status              string                 `json:"status"`
+	State               string                 `json:"state,omitempty"`
+	LastTransitionTime  *time.Time             `json:"lastTransitionTime,omitempty"`
+	Transitions         []stateTransition      `json:"transitions,omitempty"`
+	Backend             string                 `json:"backend,omitempty"`
+	Endpoint            string                 `json:"endpoint,omitempty"`
+	LastCheckTime       *time.Time             `json:"lastCheckTime,omitempty"`
+	LastSuccessTime     *time.Time             `json:"lastSuccessTime,omitempty"`
+	LatencyMs           int64                  `json:"latencyMs"`
+	StepLatencyMs       map[string]int64       `json:"stepLatencyMs,omitempty"`
+	Attempts            int                    `json:"attempts"`
+	ConsecutiveFailures int                    `json:"consecutiveFailures"`
+	LastError           string                 `json:"lastError,omitempty"`
+	Details             map[string]interface{} `json:"details,omitempty"`
+	Certificates        []certificateExpiry    `json:"certificates,omitempty"`
+	Checks              []CheckReport          `json:"checks,omitempty"`
+}
+
+// checkHistory keeps the values which are accumulated between the checks
+type checkHistory struct {
+	lastSuccessTime     *time.Time
+	consecutiveFailures int
+}
+
+func (h *checkHistory) update(healthy bool, now time.Time) {
+	if healthy {
+		h.lastSuccessTime = &now
+		h.consecutiveFailures = 0
+	} else {
+		h.consecutiveFailures += 1
+	}
+}
+
+// healthReport stores the latest check results for the verbose readiness response
+type healthReport struct {
+	mu            sync.RWMutex
+	lastCheckTime *time.Time
+	last          Result
+	total         checkHistory
+	checks        map[string]*checkHistory
+}
+
+func newHealthReport() *healthReport {
+	return &healthReport{checks: map[string]*checkHistory{}}
+}
+
+func (r *healthReport) update(res Result, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lastCheckTime = &now
+	r.last = res
+	r.total.update(res.Healthy, now)
+	for _, sub := range res.Checks {
+		history, ok := r.checks[sub.Name]
+		if !ok {
+			history = &checkHistory{}
+			r.checks[sub.Name] = history
+		}
+		history.update(sub.Healthy, now)
+	}
+}
+
+// snapshot returns the report of the latest check, the status is taken from the current readiness
+func (r *healthReport) snapshot(healthy bool) CheckReport {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	report := r.toReport(r.last, &r.total)
+	report.Status = statusOf(healthy, r.last.Degraded)
+	for _, sub := range r.last.Checks {
+		report.Checks = append(report.Checks, r.toReport(sub, r.checks[sub.Name]))
+	}
+	return report
+}
+
+func (r *healthReport) toReport(res Result, history *checkHistory) CheckReport {
+	report := CheckReport{
+		Name:          res.Name,
+		Status:        statusOf(res.Healthy, res.Degraded),
+		Backend:       res.Backend,
+		Endpoint:      res.Endpoint,
+		LastCheckTime: r.lastCheckTime,
+		LatencyMs:     res.Latency.Milliseconds(),
+		Attempts:      res.Attempts,
+		LastError:     errorString(res.Err),
+		Details:       res.Details,
+		Certificates:  res.Certificates,
+	}
+	for step, latency := range res.Steps {
+		if report.StepLatencyMs == nil {
+			report.StepLatencyMs = map[string]int64{}
+		}
+		report.StepLatencyMs[step] = latency.Milliseconds()
+	}
+	if history != nil {
+		report.LastSuccessTime = history.lastSuccessTime
+		report.ConsecutiveFailures = history.consecutiveFailures
+	}
+	return report
+}
+
+func statusOf(healthy bool, degraded bool) string {
+	if healthy && degraded {
+		return statusDegraded
+	}
+	if healthy {
+		return statusUp
+	}
+	return statusDown
+}
+
+// wantsJSON checks if the client requested the verbose JSON report
+func wantsJSON(verbose string, accept string) bool {
+	switch strings.ToLower(verbose) {
+	case "1", "true", "yes":
+		return true
+	}
+	return strings.Contains(accept, "application/json")
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHealthReport_Update(t *testing.T) {
+	report := newHealthReport()
+	first := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	report.update(Result{Healthy: true, Backend: cassandra, Endpoint: "cassandra:9042", Attempts: 1, Latency: 15 * time.Millisecond}, first)
+	report.update(Result{Healthy: false, Backend: cassandra, Endpoint: "cassandra:9042", Attempts: 3, Err: errors.New("timeout")}, first.Add(time.Minute))
+	report.update(Result{Healthy: false, Backend: cassandra, Endpoint: "cassandra:9042", Attempts: 3, Err: errors.New("timeout")}, first.Add(2*time.Minute))
+
+	snapshot := report.snapshot(false)
+	if snapshot.Status != statusDown {
+		t.Errorf("expected status %s, got %s", statusDown, snapshot.Status)
+	}
+	if snapshot.ConsecutiveFailures != 2 {
+		t.Errorf("expected 2 consecutive failures, got %d", snapshot.ConsecutiveFailures)
+	}
+	if snapshot.LastSuccessTime == nil || !snapshot.LastSuccessTime.Equal(first) {
+		t.Errorf("expected last success time %s, got %v", first, snapshot.LastSuccessTime)
+	}
+	if snapshot.LastCheckTime == nil || !snapshot.LastCheckTime.Equal(first.Add(2*time.Minute)) {
+		t.Errorf("unexpected last check time %v", snapshot.LastCheckTime)
+	}
+	if snapshot.LastError != "timeout" || snapshot.Attempts != 3 || snapshot.Backend != cassandra {
+		t.Errorf("unexpected report: %+v", snapshot)
+	}
+
+	report.update(Result{Healthy: true, Backend: cassandra}, first.Add(3*time.Minute))
+	if snapshot := report.snapshot(true); snapshot.ConsecutiveFailures != 0 || snapshot.LastError != "" {
+		t.Errorf("expected failures to be reset, got %+v", snapshot)
+	}
+}
+
+func TestHealthReport_SubChecks(t *testing.T) {
+	report := newHealthReport()
+	now := time.Now()
+	res := Result{Backend: composite, Checks: []Result{
+		{Name: "main", Healthy: true, Backend: cassandra},
+		{Name: "archive", Healthy: false, Backend: opensearch, Err: errors.New("red")},
+	}}
+	report.update(res, now)
+	report.update(res, now.Add(time.Second))
+
+	snapshot := report.snapshot(false)
+	if len(snapshot.Checks) != 2 {
+		t.Fatalf("expected 2 sub-checks, got %d", len(snapshot.Checks))
+	}
+	if snapshot.Checks[0].Name != "main" || snapshot.Checks[0].Status != statusUp || snapshot.Checks[0].LastSuccessTime == nil {
+		t.Errorf("unexpected main report: %+v", snapshot.Checks[0])
+	}
+	if snapshot.Checks[1].Status != statusDown || snapshot.Checks[1].ConsecutiveFailures != 2 || snapshot.Checks[1].LastError != "red" {
+		t.Errorf("unexpected archive report: %+v", snapshot.Checks[1])
+	}
+}
+
+func TestWantsJSON(t *testing.T) {
+	tests := []struct {
+		verbose string
+		accept  string
+		want    bool
+	}{
+		{"1", "", true},
+		{"true", "", true},
+		{"", "application/json", true},
+		{"", "text/html, application/json;q=0.9", true},
+		{"", "", false},
+		{"0", "text/plain", false},
+	}
+	for _, tt := range tests {
+		if got := wantsJSON(tt.verbose, tt.accept); got != tt.want {
+			t.Errorf("wantsJSON(%q, %q) = %v, want %v", tt.verbose, tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestReadinessProbe_Verbose(t *testing.T) {
+	server := &Server{
+		checker: &stubChecker{result: Result{Healthy: true, Backend: opensearch, Endpoint: "http://os:9200", Attempts: 1}},
+		report:  newHealthReport(),
+	}
+	server.isHealth(context.Background())
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/health?verbose=1", nil)
+	server.readinessProbe(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("expected application/json, got %s", ct)
+	}
+	var report CheckReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if report.Status != statusUp || report.Backend != opensearch || report.Endpoint != "http://os:9200" || report.LastCheckTime == nil {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if report.State != stateReady || report.LastTransitionTime == nil || len(report.Transitions) != 1 {
+		t.Fatalf("unexpected readiness state in report: %+v", report)
+	}
+}
+
+func TestReadinessProbe_AcceptJSON_Unhealthy(t *testing.T) {
+	server := &Server{
+		checker: &stubChecker{result: Result{Healthy: false, Backend: cassandra, Err: errors.New("no hosts available")}},
+		report:  newHealthReport(),
+	}
+	server.isHealth(context.Background())
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	req.Header.Set("Accept", "application/json")
+	server.readinessProbe(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", rec.Code)
+	}
+	var report CheckReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if report.Status != statusDown || report.LastError != "no hosts available" || report.ConsecutiveFailures != 1 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+}
+
+func TestHealthReport_Degraded(t *testing.T) {
+	report := newHealthReport()
+	report.update(Result{
+		Healthy:  true,
+		Degraded: true,
+		Backend:  cassandra,
+		Err:      errors.New("not enough live replicas"),
+		Details:  map[string]interface{}{"topology": &topologyState{}},
+	}, time.Now())
+	snapshot := report.snapshot(true)
+	if snapshot.Status != statusDegraded || snapshot.LastError != "not enough live replicas" || snapshot.Details["topology"] == nil {
+		t.Fatalf("unexpected report: %+v", snapshot)
+	}
+	if report.snapshot(false).Status != statusDown {
+		t.Fatal("expected DOWN status when the probe is not ready")
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// defaultCheckInterval is the pause between the checks when the interval is not configured
+const defaultCheckInterval = 10 * time.Second
+
+// retryJitter is the jitter of the delays between the attempts of the single check
+const retryJitter = 0.1
+
+// backoff returns the exponentially growing delays with the random jitter, the zero value returns no delay
+type backoff struct {
+	base time.Duration
+	max  time.Duration
+	// jitter is the fraction of the delay which is randomly added or subtracted
+	jitter float64
+}
+
+// delay returns the delay after the given number of consecutive failures starting from 1,
+// the delay is doubled after each failure up to the maximum
+func (b backoff) delay(failures int) time.Duration {
+	if b.base <= 0 || failures < 1 {
+		return 0
+	}
+	d := b.base
+	for i := 1; i < failures && (b.max <= 0 || d < b.max) && i < 32; i++ {
+		d *= 2
+	}
+	if b.max > 0 && d > b.max {
+		d = b.max
+	}
+	return withJitter(d, b.jitter)
+}
+
+// withJitter randomly changes the delay by the fraction of jitter
+func withJitter(d time.Duration, jitter float64) time.Duration {
+	if jitter <= 0 || d <= 0 {
+		return d
+	}
+	return d + time.Duration((rand.Float64()*2-1)*jitter*float64(d))
+}
+
+// scheduler decides when the next check runs. The ready storage is checked with the base interval,
+// the failing not ready storage is checked with the growing interval to reduce the load on the broken storage
+// and the recovering storage is checked faster to become ready sooner.
+type scheduler struct {
+	interval         time.Duration
+	maxInterval      time.Duration
+	recoveryInterval time.Duration
+	startupJitter    time.Duration
+	jitter           float64
+
+	// failures is the number of consecutive failed checks of the not ready storage
+	failures int
+}
+
+// first returns the random delay of the first check, so the replicas started together don't check the storage at once
+func (s *scheduler) first() time.Duration {
+	if s.startupJitter <= 0 {
+		return 0
+	}
+	return rand.N(s.startupJitter)
+}
+
+// next returns the delay before the next check by the result of the check and the readiness after it
+func (s *scheduler) next(healthy bool, ready bool) time.Duration {
+	interval := s.interval
+	if interval <= 0 {
+		interval = defaultCheckInterval
+	}
+	switch {
+	case !healthy && !ready:
+		s.failures += 1
+		return backoff{base: interval, max: max(s.maxInterval, interval), jitter: s.jitter}.delay(s.failures)
+	case healthy && !ready && s.recoveryInterval > 0:
+		s.failures = 0
+		return withJitter(min(s.recoveryInterval, interval), s.jitter)
+	}
+	// The failed check of the ready storage is repeated with the base interval until the failure threshold
+	s.failures = 0
+	return withJitter(interval, s.jitter)
+}
+
+// retryAfter returns the delay from the Retry-After header in seconds or in the HTTP date format
+func retryAfter(header string, now time.Time) (time.Duration, bool) {
+	if header == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	date, err := http.ParseTime(header)
+	if err != nil {
+		return 0, false
+	}
+	return max(date.Sub(now), 0), true
+}
+
+// retryAfterError is returned when the storage asks to retry later than the check can wait,
+// the next check is scheduled not earlier than after the delay
+type retryAfterError struct {
+	err   error
+	delay time.Duration
+}
+
+func (e *retryAfterError) Error() string {
+	return fmt.Sprintf("%s, the storage asks to retry after %s", e.err.Error(), e.delay)
+}
+
+func (e *retryAfterError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoff_Delay(t *testing.T) {
+	b := backoff{base: time.Second, max: 10 * time.Second}
+	want := []time.Duration{0, time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}
+	for failures, delay := range want {
+		if got := b.delay(failures); got != delay {
+			t.Errorf("delay(%d) = %s, want %s", failures, got, delay)
+		}
+	}
+	if got := (backoff{}).delay(3); got != 0 {
+		t.Errorf("expected no delay for the zero backoff, got %s", got)
+	}
+	if got := (backoff{base: time.Second}).delay(100); got <= 0 {
+		t.Errorf("expected the unlimited delay not to overflow, got %s", got)
+	}
+}
+
+func TestWithJitter(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		got := withJitter(10*time.Second, 0.2)
+		if got < 8*time.Second || got > 12*time.Second {
+			t.Fatalf("expected the delay within 20%% of 10s, got %s", got)
+		}
+	}
+	if got := withJitter(10*time.Second, 0); got != 10*time.Second {
+		t.Errorf("expected no jitter, got %s", got)
+	}
+}
+
+func TestScheduler_Next(t *testing.T) {
+	s := &scheduler{interval: 10 * time.Second, maxInterval: 30 * time.Second, recoveryInterval: 2 * time.Second}
+	steps := []struct {
+		healthy bool
+		ready   bool
+		want    time.Duration
+	}{
+		{true, true, 10 * time.Second},
+		// The ready storage is checked with the base interval until the failure threshold
+		{false, true, 10 * time.Second},
+		{false, false, 10 * time.Second},
+		{false, false, 20 * time.Second},
+		{false, false, 30 * time.Second},
+		{false, false, 30 * time.Second},
+		// The recovering storage is checked faster
+		{true, false, 2 * time.Second},
+		{false, false, 10 * time.Second},
+		{true, true, 10 * time.Second},
+	}
+	for i, step := range steps {
+		if got := s.next(step.healthy, step.ready); got != step.want {
+			t.Errorf("step %d: next(%v, %v) = %s, want %s", i, step.healthy, step.ready, got, step.want)
+		}
+	}
+}
+
+func TestScheduler_ZeroValue(t *testing.T) {
+	var s scheduler
+	if got := s.first(); got != 0 {
+		t.Errorf("expected no startup delay, got %s", got)
+	}
+	if got := s.next(true, true); got != defaultCheckInterval {
+		t.Errorf("expected the default interval, got %s", got)
+	}
+}
+
+func TestScheduler_StartupJitter(t *testing.T) {
+	s := &scheduler{startupJitter: time.Second}
+	for i := 0; i < 100; i++ {
+		if got := s.first(); got < 0 || got >= time.Second {
+			t.Fatalf("expected the startup delay below 1s, got %s", got)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		header string
+		want   time.Duration
+		ok     bool
+	}{
+		{"", 0, false},
+		{"30", 30 * time.Second, true},
+		{"0", 0, true},
+		{"-1", 0, false},
+		{"Thu, 01 Jan 2026 10:01:00 GMT", time.Minute, true},
+		{"Thu, 01 Jan 2026 09:59:00 GMT", 0, true},
+		{"soon", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := retryAfter(tt.header, now)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("retryAfter(%q) = %s, %v, want %s, %v", tt.header, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// credentialsUpdater is implemented by the checkers which can swap the credentials without the restart
+type credentialsUpdater interface {
+	updateCredentials(creds authCredentials) error
+}
+
+// secretWatcher keeps the credentials of the checker in sync with the Secret
+type secretWatcher struct {
+	client    kubernetes.Interface
+	namespace string
+	name      string
+	authType  string
+	keyNames  keyNames
+	updater   credentialsUpdater
+
+	// mu serializes the updates from the informer and from the retry of the failed check
+	mu      sync.Mutex
+	current authCredentials
+	stop    chan struct{}
+}
+
+func newSecretWatcher(client kubernetes.Interface, cfg *Config, updater credentialsUpdater) *secretWatcher {
+	return &secretWatcher{
+		client:    client,
+		namespace: cfg.namespace,
+		name:      cfg.authSecretName,
+		authType:  cfg.authType,
+		keyNames:  cfg.keyNames,
+		updater:   updater,
+		current:   cfg.authCredentials,
+	}
+}
+
+// start runs the informer of the single Secret, the informer lists the Secret again after the watch is broken
+func (w *secretWatcher) start() {
+	factory := informers.NewSharedInformerFactoryWithOptions(w.client, 0,
+		informers.WithNamespace(w.namespace),
+		informers.WithTweakListOptions(func(opts *metaV1.ListOptions) {
+			opts.FieldSelector = fields.OneTermEqualSelector("metadata.name", w.name).String()
+		}))
+	informer := factory.Core().V1().Secrets().Informer()
+	_, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			w.onSecret(obj)
+		},
+		UpdateFunc: func(_, obj interface{}) {
+			w.onSecret(obj)
+		},
+		DeleteFunc: func(_ interface{}) {
+			slog.Warn(fmt.Sprintf("Secret '%s/%s' is deleted, the last credentials are kept", w.namespace, w.name))
+		},
+	})
+	if err != nil {
+		slog.Error(fmt.Sprintf("Can't watch the secret '%s/%s': %s", w.namespace, w.name, err.Error()))
+		return
+	}
+	w.stop = make(chan struct{})
+	factory.Start(w.stop)
+	slog.Info(fmt.Sprintf("Watching the secret '%s/%s' for the credentials changes", w.namespace, w.name))
+}
+
+// close stops the informer
+func (w *secretWatcher) close() {
+	if w.stop != nil {
+		close(w.stop)
+		w.stop = nil
+	}
+}
+
+func (w *secretWatcher) onSecret(obj interface{}) {
+	secret, ok := obj.(*v1.Secret)
+	if !ok {
+		return
+	}
+	if _, err := w.apply(secret); err != nil {
+		slog.Error(err.Error())
+	}
+}
+
+// refresh reads the Secret from the API bypassing the informer cache, it returns true when the credentials are changed
+func (w *secretWatcher) refresh(ctx context.Context) (bool, error) {
+	secret, err := w.client.CoreV1().Secrets(w.namespace).Get(ctx, w.name, metaV1.GetOptions{})
+	if err != nil {
+		return false, fmt.Errorf("can't read the secret '%s/%s': %w", w.namespace, w.name, err)
+	}
+	return w.apply(secret)
+}
+
+// apply swaps the credentials of the checker when they differ from the current ones,
+// the invalid Secret is ignored and the current credentials are kept
+func (w *secretWatcher) apply(secret *v1.Secret) (bool, error) {
+	source := &secretSource{namespace: w.namespace, name: w.name, secret: secret}
+	creds, err := readCredentials(source, w.authType, w.keyNames)
+	if err != nil {
+		return false, fmt.Errorf("the credentials from the %s are not updated: %w", source, err)
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if creds == w.current {
+		return false, nil
+	}
+	if err := w.updater.updateCredentials(creds); err != nil {
+		return false, fmt.Errorf("the credentials from the secret '%s/%s' are not applied: %w", w.namespace, w.name, err)
+	}
+	w.current = creds
+	slog.Info(fmt.Sprintf("The credentials from the secret '%s/%s' are updated", w.namespace, w.name))
+	return true, nil
+}
+
+// reloadingChecker retries the check once with the fresh credentials after the authentication failure
+type reloadingChecker struct {
+	HealthChecker
+	watcher *secretWatcher
+}
+
+func (r *reloadingChecker) Check(ctx context.Context) Result {
+	res := r.HealthChecker.Check(ctx)
+	if res.Healthy || !isAuthFailure(res.Err) {
+		return res
+	}
+	changed, err := r.watcher.refresh(ctx)
+	if err != nil {
+		slog.Error(err.Error())
+		return res
+	}
+	if !changed {
+		return res
+	}
+	slog.Info("Retrying the check with the updated credentials after the authentication failure")
+	retry := r.HealthChecker.Check(ctx)
+	retry.Attempts += res.Attempts
+	return retry
+}
+
+func (r *reloadingChecker) Close() {
+	r.watcher.close()
+	r.HealthChecker.Close()
+}
+
+// isAuthFailure reports whether the check failed because the storage rejected the credentials
+func isAuthFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	if classifyError(err) == reasonAuth {
+		return true
+	}
+	// The driver returns the authentication errors of the new session as text
+	message := strings.ToLower(err.Error())
+	return strings.Contains(message, "authentication") || strings.Contains(message, "bad credentials")
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	v1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func basicAuthSecret(user string, password string) *v1.Secret {
+	return &v1.Secret{
+		ObjectMeta: metaV1.ObjectMeta{Namespace: "tracing", Name: "jaeger-elasticsearch"},
+		Data: map[string][]byte{
+			usernameKey: []byte(user),
+			passwordKey: []byte(password),
+		},
+	}
+}
+
+// credentialsRecorder records the credentials applied by the watcher
+type credentialsRecorder struct {
+	updates chan authCredentials
+	err     error
+}
+
+func (r *credentialsRecorder) updateCredentials(creds authCredentials) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.updates <- creds
+	return nil
+}
+
+func newWatchConfig(user string, password string) *Config {
+	return &Config{
+		namespace:       "tracing",
+		authSecretName:  "jaeger-elasticsearch",
+		authType:        basicAuth,
+		authCredentials: authCredentials{user: user, password: password},
+	}
+}
+
+func TestReadCredentials_SecretMissingKey(t *testing.T) {
+	source := &secretSource{secret: basicAuthSecret("admin", "")}
+	if _, err := readCredentials(source, basicAuth, nil); err == nil || !strings.Contains(err.Error(), "'password'") {
+		t.Fatalf("expected missing password error, got %v", err)
+	}
+	source = &secretSource{secret: basicAuthSecret("admin", "secret")}
+	creds, err := readCredentials(source, basicAuth, nil)
+	if err != nil || creds.user != "admin" || creds.password != "secret" {
+		t.Fatalf("unexpected credentials %+v, error %v", creds, err)
+	}
+}
+
+func TestSecretWatcher_InformerUpdates(t *testing.T) {
+	client := fake.NewSimpleClientset(basicAuthSecret("admin", "old"))
+	recorder := &credentialsRecorder{updates: make(chan authCredentials, 1)}
+	watcher := newSecretWatcher(client, newWatchConfig("admin", "old"), recorder)
+	watcher.start()
+	defer watcher.close()
+
+	// The fake watch is established asynchronously, so the Secret is updated until the change is seen
+	deadline := time.After(5 * time.Second)
+	for i := 0; ; i++ {
+		secret := basicAuthSecret("admin", fmt.Sprintf("new-%d", i))
+		if _, err := client.CoreV1().Secrets("tracing").Update(context.Background(), secret, metaV1.UpdateOptions{}); err != nil {
+			t.Fatalf("update secret: %v", err)
+		}
+		select {
+		case creds := <-recorder.updates:
+			if !strings.HasPrefix(creds.password, "new-") {
+				t.Fatalf("unexpected password %q", creds.password)
+			}
+			return
+		case <-time.After(100 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("the credentials are not updated by the informer")
+		}
+	}
+}
+
+func TestSecretWatcher_InvalidSecretKeepsCredentials(t *testing.T) {
+	recorder := &credentialsRecorder{updates: make(chan authCredentials, 1)}
+	watcher := newSecretWatcher(fake.NewSimpleClientset(), newWatchConfig("admin", "old"), recorder)
+
+	if _, err := watcher.apply(basicAuthSecret("", "new")); err == nil {
+		t.Fatal("expected error for the secret without username")
+	}
+	if changed, err := watcher.apply(basicAuthSecret("admin", "old")); changed || err != nil {
+		t.Fatalf("expected the same credentials to be skipped, got %v, %v", changed, err)
+	}
+	recorder.err = errors.New("boom")
+	if _, err := watcher.apply(basicAuthSecret("admin", "new")); err == nil {
+		t.Fatal("expected error from the checker")
+	}
+	if watcher.current.password != "old" {
+		t.Errorf("expected the current credentials to be kept, got %+v", watcher.current)
+	}
+}
+
+func TestReloadingChecker_RetryAfterAuthFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, password, _ := r.BasicAuth(); password != "rotated" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(greenClusterHealth))
+	}))
+	defer srv.Close()
+
+	cfg := newWatchConfig("admin", "old")
+	cfg.hosts = hostList{srv.URL}
+	cfg.minClusterStatus = "green"
+	cfg.errorsCount = 1
+	cfg.timeout = 1
+	checker, err := newOpensearchChecker(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	client := fake.NewSimpleClientset(basicAuthSecret("admin", "rotated"))
+	reloading := &reloadingChecker{
+		HealthChecker: checker,
+		watcher:       newSecretWatcher(client, cfg, checker.(credentialsUpdater)),
+	}
+	defer reloading.Close()
+
+	res := reloading.Check(context.Background())
+	if !res.Healthy {
+		t.Fatalf("expected healthy result after the retry, got %v", res.Err)
+	}
+	if res.Attempts != 2 {
+		t.Errorf("expected 2 attempts, got %d", res.Attempts)
+	}
+}
+
+func TestReloadingChecker_NoRetryWithSameCredentials(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	cfg := newWatchConfig("admin", "old")
+	cfg.hosts = hostList{srv.URL}
+	cfg.minClusterStatus = "green"
+	cfg.errorsCount = 1
+	cfg.timeout = 1
+	checker, err := newOpensearchChecker(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	client := fake.NewSimpleClientset(basicAuthSecret("admin", "old"))
+	reloading := &reloadingChecker{
+		HealthChecker: checker,
+		watcher:       newSecretWatcher(client, cfg, checker.(credentialsUpdater)),
+	}
+	if res := reloading.Check(context.Background()); res.Healthy {
+		t.Fatal("expected unhealthy result")
+	}
+	if calls != 1 {
+		t.Errorf("expected no retry with the same credentials, got %d requests", calls)
+	}
+}
+
+func TestCassandraUpdateCredentials_KeepsSessionOnError(t *testing.T) {
+	session := &mockCassandraSession{}
+	checker := &cassandraChecker{
+		session: session,
+		connect: func(user string, password string) (*gocql.Session, error) {
+			return nil, errors.New("authentication failed")
+		},
+	}
+	if err := checker.updateCredentials(authCredentials{user: "u", password: "p"}); err == nil {
+		t.Fatal("expected error when the session can't be created")
+	}
+	if checker.session != session || session.closed {
+		t.Error("expected the previous session to be kept open")
+	}
+}
+
+func TestIsAuthFailure(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{&httpStatusError{code: http.StatusUnauthorized}, true},
+		{&httpStatusError{code: http.StatusServiceUnavailable}, false},
+		{errors.New("gocql: unable to create session: authentication failed: Provided username admin and/or password are incorrect"), true},
+		{errors.New("connection refused"), false},
+	}
+	for _, tt := range tests {
+		if got := isAuthFailure(tt.err); got != tt.want {
+			t.Errorf("isAuthFailure(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+// blockingSession blocks the queries until it is released
+type blockingSession struct {
+	mockCassandraSession
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingSession) Query(stmt string, values ...interface{}) Query {
+	b.started <- struct{}{}
+	<-b.release
+	return b.mockCassandraSession.Query(stmt, values...)
+}
+
+func TestCassandraUpdateCredentials_DoesNotWaitForCheck(t *testing.T) {
+	session := &blockingSession{started: make(chan struct{}), release: make(chan struct{})}
+	checker := &cassandraChecker{
+		session:     session,
+		errorsCount: 1,
+		connect: func(user string, password string) (*gocql.Session, error) {
+			return &gocql.Session{}, nil
+		},
+	}
+	done := make(chan Result)
+	go func() {
+		done <- checker.Check(context.Background())
+	}()
+	<-session.started
+
+	updated := make(chan error)
+	go func() {
+		updated <- checker.updateCredentials(authCredentials{user: "u", password: "p"})
+	}()
+	select {
+	case err := <-updated:
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the credentials to be updated during the check")
+	}
+
+	close(session.release)
+	if res := <-done; !res.Healthy {
+		t.Fatalf("expected the running check to finish with the previous session, got %v", res.Err)
+	}
+	if !session.closed {
+		t.Error("expected the previous session to be closed after the check")
+	}
+}
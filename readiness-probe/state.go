@@ -0,0 +1,131 @@
+package main
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Readiness states of the probe
+const (
+	stateUnknown  string = "Unknown"
+	stateReady    string = "Ready"
+	stateNotReady string = "NotReady"
+	stateDegraded string = "Degraded"
+)
+
+// maxTransitions is the number of the latest transitions kept for the health report
+const maxTransitions = 10
+
+// stateTransition describes the change of the readiness state in the health report
+type stateTransition struct {
+	From   string    `json:"from"`
+	To     string    `json:"to"`
+	Time   time.Time `json:"time"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+// healthState is the readiness state machine with the Kubernetes-style thresholds:
+// the ready probe becomes not ready after failureThreshold consecutive failed checks
+// and the not ready probe becomes ready after successThreshold consecutive successful checks.
+// The zero value is the Unknown state with both thresholds equal to 1.
+type healthState struct {
+	mu               sync.RWMutex
+	successThreshold int
+	failureThreshold int
+
+	state                string
+	lastTransitionTime   *time.Time
+	consecutiveSuccesses int
+	consecutiveFailures  int
+	transitions          []stateTransition
+}
+
+// stateSnapshot is the copy of the state for the readiness response
+type stateSnapshot struct {
+	State              string
+	LastTransitionTime *time.Time
+	Transitions        []stateTransition
+}
+
+// observe applies the result of the check and returns the new state
+func (h *healthState) observe(res Result, now time.Time) string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	current := h.current()
+	if res.Healthy {
+		h.consecutiveSuccesses += 1
+		h.consecutiveFailures = 0
+	} else {
+		h.consecutiveFailures += 1
+		h.consecutiveSuccesses = 0
+	}
+
+	next := current
+	switch {
+	case res.Healthy && (isReady(current) || h.consecutiveSuccesses >= max(h.successThreshold, 1)):
+		next = stateReady
+		if res.Degraded {
+			next = stateDegraded
+		}
+	case !res.Healthy && (!isReady(current) || h.consecutiveFailures >= max(h.failureThreshold, 1)):
+		// The probe which has never been ready doesn't wait for the failure threshold
+		next = stateNotReady
+	}
+	if next != current {
+		h.transition(current, next, res, now)
+	}
+	return next
+}
+
+func (h *healthState) transition(from string, to string, res Result, now time.Time) {
+	h.state = to
+	h.lastTransitionTime = &now
+	t := stateTransition{From: from, To: to, Time: now, Reason: errorString(res.Err)}
+	h.transitions = append(h.transitions, t)
+	if len(h.transitions) > maxTransitions {
+		h.transitions = h.transitions[len(h.transitions)-maxTransitions:]
+	}
+	attrs := []any{
+		"from", from,
+		"to", to,
+		"consecutiveSuccesses", h.consecutiveSuccesses,
+		"consecutiveFailures", h.consecutiveFailures,
+	}
+	if res.Err != nil {
+		attrs = append(attrs, "reason", res.Err.Error())
+	}
+	if isReady(to) && to != stateDegraded {
+		slog.Info("Readiness state changed", attrs...)
+	} else {
+		slog.Warn("Readiness state changed", attrs...)
+	}
+}
+
+func (h *healthState) current() string {
+	if h.state == "" {
+		return stateUnknown
+	}
+	return h.state
+}
+
+// ready reports whether the storage is ready, the degraded storage is ready
+func (h *healthState) ready() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return isReady(h.current())
+}
+
+func (h *healthState) snapshot() stateSnapshot {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return stateSnapshot{
+		State:              h.current(),
+		LastTransitionTime: h.lastTransitionTime,
+		Transitions:        append([]stateTransition(nil), h.transitions...),
+	}
+}
+
+func isReady(state string) bool {
+	return state == stateReady || state == stateDegraded
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHealthState_ZeroValue(t *testing.T) {
+	var state healthState
+	if state.ready() || state.snapshot().State != stateUnknown {
+		t.Fatalf("expected not ready Unknown state, got %+v", state.snapshot())
+	}
+	if got := state.observe(Result{Healthy: true}, time.Now()); got != stateReady {
+		t.Fatalf("expected Ready after the first success, got %s", got)
+	}
+}
+
+func TestHealthState_FailureThreshold(t *testing.T) {
+	state := healthState{successThreshold: 1, failureThreshold: 3}
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	state.observe(Result{Healthy: true}, now)
+
+	for i := 1; i < 3; i++ {
+		if got := state.observe(Result{Healthy: false, Err: errors.New("timeout")}, now.Add(time.Duration(i)*time.Second)); got != stateReady {
+			t.Fatalf("expected Ready after %d failures, got %s", i, got)
+		}
+	}
+	if got := state.observe(Result{Healthy: false, Err: errors.New("timeout")}, now.Add(3*time.Second)); got != stateNotReady {
+		t.Fatalf("expected NotReady after 3 failures, got %s", got)
+	}
+	snapshot := state.snapshot()
+	if snapshot.LastTransitionTime == nil || !snapshot.LastTransitionTime.Equal(now.Add(3*time.Second)) {
+		t.Errorf("unexpected last transition time %v", snapshot.LastTransitionTime)
+	}
+	last := snapshot.Transitions[len(snapshot.Transitions)-1]
+	if last.From != stateReady || last.To != stateNotReady || last.Reason != "timeout" {
+		t.Errorf("unexpected transition %+v", last)
+	}
+}
+
+func TestHealthState_SuccessThreshold(t *testing.T) {
+	state := healthState{successThreshold: 2, failureThreshold: 3}
+	now := time.Now()
+	if got := state.observe(Result{Healthy: false}, now); got != stateNotReady {
+		t.Fatalf("expected Unknown to become NotReady without the threshold, got %s", got)
+	}
+	if got := state.observe(Result{Healthy: true}, now); got != stateNotReady {
+		t.Fatalf("expected NotReady after 1 success, got %s", got)
+	}
+	// The failure resets the consecutive successes
+	state.observe(Result{Healthy: false}, now)
+	state.observe(Result{Healthy: true}, now)
+	if got := state.observe(Result{Healthy: true}, now); got != stateReady {
+		t.Fatalf("expected Ready after 2 successes, got %s", got)
+	}
+}
+
+func TestHealthState_Degraded(t *testing.T) {
+	state := healthState{successThreshold: 1, failureThreshold: 1}
+	now := time.Now()
+	state.observe(Result{Healthy: true}, now)
+	if got := state.observe(Result{Healthy: true, Degraded: true}, now); got != stateDegraded || !state.ready() {
+		t.Fatalf("expected ready Degraded state, got %s", got)
+	}
+	if got := state.observe(Result{Healthy: true}, now); got != stateReady {
+		t.Fatalf("expected Ready, got %s", got)
+	}
+	if got := len(state.snapshot().Transitions); got != 3 {
+		t.Errorf("expected 3 transitions, got %d", got)
+	}
+}
+
+func TestHealthState_TransitionsLimit(t *testing.T) {
+	state := healthState{successThreshold: 1, failureThreshold: 1}
+	now := time.Now()
+	for i := 0; i < maxTransitions+5; i++ {
+		state.observe(Result{Healthy: i%2 == 0}, now.Add(time.Duration(i)*time.Second))
+	}
+	transitions := state.snapshot().Transitions
+	if len(transitions) != maxTransitions {
+		t.Fatalf("expected %d transitions, got %d", maxTransitions, len(transitions))
+	}
+	if !transitions[len(transitions)-1].Time.Equal(now.Add(time.Duration(maxTransitions+4) * time.Second)) {
+		t.Errorf("expected the latest transition to be kept, got %+v", transitions[len(transitions)-1])
+	}
+}
+
+func TestHealthState_Concurrent(t *testing.T) {
+	state := healthState{successThreshold: 1, failureThreshold: 2}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				state.observe(Result{Healthy: j%3 != 0}, time.Now())
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				state.ready()
+				state.snapshot()
+			}
+		}()
+	}
+	wg.Wait()
+}
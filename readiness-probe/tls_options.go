@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// pinPrefix is the optional prefix of the pin in the format of the HTTP Public Key Pinning
+const pinPrefix = "sha256/"
+
+// tlsOptions contains the TLS parameters shared by all backends
+type tlsOptions struct {
+	// The empty caPath uses only the system trust store, the empty crtPath and keyPath don't send the client certificate
+	caPath  string
+	crtPath string
+	keyPath string
+	// The Kubernetes objects with the material are used instead of the files
+	namespace          string
+	secretName         string
+	caSecretName       string
+	caConfigMapName    string
+	caKey              string
+	insecureSkipVerify bool
+	minVersion         uint16
+	cipherSuites       []uint16
+	serverName         string
+	pins               [][]byte
+}
+
+// tlsOptions returns the TLS parameters of the configuration
+func (c *Config) tlsOptions() tlsOptions {
+	return tlsOptions{
+		caPath:             c.caPath,
+		crtPath:            c.crtPath,
+		keyPath:            c.keyPath,
+		namespace:          c.namespace,
+		secretName:         c.tlsSecretName,
+		caSecretName:       c.caSecretName,
+		caConfigMapName:    c.caConfigMapName,
+		caKey:              c.caKey,
+		insecureSkipVerify: c.insecureSkipVerify,
+		minVersion:         uint16(c.tlsMinVersion),
+		cipherSuites:       c.tlsCipherSuites,
+		serverName:         c.tlsServerName,
+		pins:               c.tlsPins,
+	}
+}
+
+// fromKubernetes reports whether the material is read from the Secrets and the ConfigMap instead of the files
+func (o tlsOptions) fromKubernetes() bool {
+	return o.secretName != "" || o.caSecretName != "" || o.caConfigMapName != ""
+}
+
+// mode describes the way the server is verified for the logs
+func (o tlsOptions) mode() string {
+	var parts []string
+	if o.insecureSkipVerify {
+		parts = append(parts, "without the server verification")
+	} else {
+		parts = append(parts, "with the server verification")
+	}
+	if len(o.pins) > 0 {
+		parts = append(parts, fmt.Sprintf("%d pinned keys", len(o.pins)))
+	}
+	return strings.Join(parts, ", ")
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// tlsVersion is the minimal TLS version in format 1.2
+type tlsVersion uint16
+
+func (v *tlsVersion) String() string {
+	for name, version := range tlsVersions {
+		if uint16(*v) == version {
+			return name
+		}
+	}
+	return ""
+}
+
+func (v *tlsVersion) Set(value string) error {
+	version, ok := tlsVersions[strings.TrimPrefix(strings.TrimSpace(value), "TLS")]
+	if !ok {
+		return fmt.Errorf("unknown TLS version '%s', possible values: 1.0, 1.1, 1.2, 1.3", value)
+	}
+	*v = tlsVersion(version)
+	return nil
+}
+
+// cipherSuites collects the cipher suites by the names from the crypto/tls package
+type cipherSuites []uint16
+
+func (c *cipherSuites) String() string {
+	names := make([]string, 0, len(*c))
+	for _, id := range *c {
+		names = append(names, tls.CipherSuiteName(id))
+	}
+	return strings.Join(names, ",")
+}
+
+func (c *cipherSuites) Set(value string) error {
+	known := map[string]uint16{}
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		known[suite.Name] = suite.ID
+	}
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		id, ok := known[name]
+		if !ok {
+			names := make([]string, 0, len(tls.CipherSuites()))
+			for _, suite := range tls.CipherSuites() {
+				names = append(names, suite.Name)
+			}
+			sort.Strings(names)
+			return fmt.Errorf("unknown cipher suite '%s', possible values: %s", name, strings.Join(names, ", "))
+		}
+		*c = append(*c, id)
+	}
+	return nil
+}
+
+// reset removes the cipher suites copied from the command line before the cipher suites of the named check are set
+func (c *cipherSuites) reset() {
+	*c = nil
+}
+
+// certPins collects the SHA-256 digests of the subject public keys in base64 or hex
+type certPins [][]byte
+
+func (p *certPins) String() string {
+	pins := make([]string, 0, len(*p))
+	for _, pin := range *p {
+		pins = append(pins, pinPrefix+base64.StdEncoding.EncodeToString(pin))
+	}
+	return strings.Join(pins, ",")
+}
+
+func (p *certPins) Set(value string) error {
+	for _, pin := range strings.Split(value, ",") {
+		if pin = strings.TrimPrefix(strings.TrimSpace(pin), pinPrefix); pin == "" {
+			continue
+		}
+		digest, err := base64.StdEncoding.DecodeString(pin)
+		if err != nil || len(digest) != sha256.Size {
+			digest, err = hex.DecodeString(strings.ReplaceAll(pin, ":", ""))
+		}
+		if err != nil || len(digest) != sha256.Size {
+			return fmt.Errorf("invalid pin '%s', expected the SHA-256 digest of the public key in base64 or hex", pin)
+		}
+		*p = append(*p, digest)
+	}
+	return nil
+}
+
+// reset removes the pins copied from the command line before the pins of the named check are set
+func (p *certPins) reset() {
+	*p = nil
+}
+
+// verifyPins checks that one of the certificates of the server chain has the pinned public key
+func verifyPins(cs tls.ConnectionState, pins [][]byte) error {
+	if len(pins) == 0 {
+		return nil
+	}
+	for _, cert := range cs.PeerCertificates {
+		digest := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+		for _, pin := range pins {
+			if bytes.Equal(digest[:], pin) {
+				return nil
+			}
+		}
+	}
+	return &tls.CertificateVerificationError{
+		UnverifiedCertificates: cs.PeerCertificates,
+		Err:                    errors.New("none of the server certificates matches the pinned public keys"),
+	}
+}
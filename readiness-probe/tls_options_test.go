@@ -0,0 +1,147 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTLSFlags(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg := &Config{}
+	cfg.bindFlags(fs)
+	if cfg.tlsMinVersion != tls.VersionTLS12 {
+		t.Errorf("expected TLS 1.2 by default, got %s", cfg.tlsMinVersion.String())
+	}
+	digest := sha256.Sum256([]byte("key"))
+	err := fs.Parse([]string{
+		"-tlsMinVersion=1.3",
+		"-tlsCipherSuites=TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256",
+		"-tlsPinnedKeys=sha256/" + base64.StdEncoding.EncodeToString(digest[:]) + "," + hex.EncodeToString(digest[:]),
+	})
+	if err != nil {
+		t.Fatalf("flag parse error: %v", err)
+	}
+	opts := cfg.tlsOptions()
+	if opts.minVersion != tls.VersionTLS13 || len(opts.cipherSuites) != 2 || len(opts.pins) != 2 {
+		t.Fatalf("unexpected TLS options: %+v", opts)
+	}
+	// The named checks copy the flags by their string values
+	var pins certPins
+	if err := pins.Set(cfg.tlsPins.String()); err != nil || len(pins) != 2 {
+		t.Errorf("expected the pins to be copied, got %v, %v", pins, err)
+	}
+
+	for _, args := range [][]string{{"-tlsMinVersion=1.4"}, {"-tlsCipherSuites=TLS_UNKNOWN"}, {"-tlsPinnedKeys=abc"}} {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		(&Config{}).bindFlags(fs)
+		if err := fs.Parse(args); err == nil {
+			t.Errorf("expected error for %v", args)
+		}
+	}
+}
+
+func TestValidate_TLSModes(t *testing.T) {
+	tests := []struct {
+		args    []string
+		wantErr bool
+	}{
+		{[]string{"-tlsEnabled"}, false},
+		{[]string{"-tlsEnabled", "-caPath=ca.crt"}, false},
+		{[]string{"-tlsEnabled", "-caPath=ca.crt", "-crtPath=tls.crt", "-keyPath=tls.key"}, false},
+		{[]string{"-tlsEnabled", "-crtPath=tls.crt"}, true},
+		{[]string{"-tlsEnabled", "-insecureSkipVerify", "-keyPath=tls.key"}, true},
+	}
+	for _, tt := range tests {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		cfg := &Config{}
+		cfg.bindFlags(fs)
+		if err := fs.Parse(append(tt.args, "-host=opensearch")); err != nil {
+			t.Fatalf("flag parse error: %v", err)
+		}
+		if err := cfg.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%v: expected error %v, got %v", tt.args, tt.wantErr, err)
+		}
+	}
+}
+
+// writeServerCA writes the certificate of the test server as the CA bundle
+func writeServerCA(t *testing.T, srv *httptest.Server) string {
+	ca := filepath.Join(t.TempDir(), "ca.crt")
+	if err := os.WriteFile(ca, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return ca
+}
+
+func TestCreateTLSConfig_Options(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	srv.StartTLS()
+	defer srv.Close()
+	ca := writeServerCA(t, srv)
+	digest := sha256.Sum256(srv.Certificate().RawSubjectPublicKeyInfo)
+	other := sha256.Sum256([]byte("other"))
+
+	tests := []struct {
+		name    string
+		opts    tlsOptions
+		wantErr string
+	}{
+		{"CA only", tlsOptions{caPath: ca}, ""},
+		{"system trust store", tlsOptions{}, "certificate"},
+		{"server name", tlsOptions{caPath: ca, serverName: "example.com"}, ""},
+		{"wrong server name", tlsOptions{caPath: ca, serverName: "opensearch.tracing.svc"}, "opensearch.tracing.svc"},
+		{"pinned key", tlsOptions{insecureSkipVerify: true, pins: [][]byte{other[:], digest[:]}}, ""},
+		{"wrong pin", tlsOptions{insecureSkipVerify: true, pins: [][]byte{other[:]}}, "pinned"},
+		{"min version", tlsOptions{caPath: ca, minVersion: tls.VersionTLS13}, "version"},
+		{"cipher suites", tlsOptions{caPath: ca, cipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384}}, ""},
+	}
+	for _, tt := range tests {
+		hc := createHttpClient("u", "p", true, tt.opts, 1)
+		res, err := hc.client.Get(srv.URL)
+		if err == nil {
+			_ = res.Body.Close()
+		}
+		if tt.wantErr == "" && err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		} else if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("%s: expected error with '%s', got %v", tt.name, tt.wantErr, err)
+		}
+		hc.certs.close()
+	}
+}
+
+func TestValidate_TLSSecrets(t *testing.T) {
+	tests := []struct {
+		args    []string
+		wantErr bool
+	}{
+		{[]string{"-tlsSecretName=jaeger-tls"}, false},
+		{[]string{"-tlsSecretName=jaeger-tls", "-caConfigMapName=kube-root-ca.crt"}, false},
+		{[]string{"-caSecretName=jaeger-ca", "-caConfigMapName=kube-root-ca.crt"}, true},
+		{[]string{"-tlsSecretName=jaeger-tls", "-caPath=ca.crt"}, true},
+	}
+	for _, tt := range tests {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		cfg := &Config{}
+		cfg.bindFlags(fs)
+		if err := fs.Parse(append(tt.args, "-host=opensearch", "-tlsEnabled")); err != nil {
+			t.Fatalf("flag parse error: %v", err)
+		}
+		if err := cfg.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%v: expected error %v, got %v", tt.args, tt.wantErr, err)
+		}
+	}
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// tlsMaterial contains the PEM encoded CA bundle, client certificate and key, the missing values are empty
+type tlsMaterial struct {
+	ca  []byte
+	crt []byte
+	key []byte
+	// clientCert is true when the source is configured with the client certificate
+	clientCert bool
+	// caBundle is true when the source is configured with the CA bundle
+	caBundle bool
+}
+
+// tlsSource provides the TLS material and notifies about its changes
+type tlsSource interface {
+	read() (tlsMaterial, error)
+	// watch calls the function after the material is changed until the source is closed
+	watch(changed func())
+	close()
+	String() string
+}
+
+// certReloader keeps the client certificate and the CA bundle loaded from the source
+// and reloads them when they are rotated, for example by cert-manager
+type certReloader struct {
+	source tlsSource
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	roots     *x509.CertPool
+	digest    [sha256.Size]byte
+	listeners []func()
+
+	// The certificates with the earliest expiry for the expiry monitoring, the server chains are kept by the server name
+	caCert  *x509.Certificate
+	servers map[string]*x509.Certificate
+}
+
+func newCertReloader(ca string, crt string, key string) *certReloader {
+	return newSourceReloader(&tlsFiles{caPath: ca, crtPath: crt, keyPath: key})
+}
+
+func newSourceReloader(source tlsSource) *certReloader {
+	r := &certReloader{source: source}
+	r.reload()
+	return r
+}
+
+// reload reads the source and swaps the certificates when they are changed, it returns true after the swap.
+// The certificates which can't be loaded are not swapped, so the rotation in progress doesn't break the connections.
+func (r *certReloader) reload() bool {
+	material, err := r.source.read()
+	if err != nil {
+		slog.Error(err.Error())
+	}
+	var content bytes.Buffer
+	for _, data := range [][]byte{material.ca, material.crt, material.key} {
+		content.Write(data)
+		content.WriteByte(0)
+	}
+	digest := sha256.Sum256(content.Bytes())
+	r.mu.RLock()
+	unchanged := digest == r.digest
+	r.mu.RUnlock()
+	if unchanged {
+		return false
+	}
+
+	// The digest of the material which failed to load is not kept, so the material is loaded again on the next change
+	loaded := true
+	var cert tls.Certificate
+	if material.clientCert {
+		if cert, err = tls.X509KeyPair(material.crt, material.key); err != nil {
+			slog.Error(fmt.Sprintf("Error loading certificate and key %s: %v", r.source, err))
+			loaded = false
+		}
+	}
+	roots, err := x509.SystemCertPool()
+	if err != nil {
+		slog.Error(err.Error())
+		roots = x509.NewCertPool()
+	}
+	// Without the CA bundle only the system trust store is used
+	var caCert *x509.Certificate
+	caLoaded := true
+	if len(material.ca) > 0 {
+		if ok := roots.AppendCertsFromPEM(material.ca); !ok {
+			slog.Error(fmt.Sprintf("Invalid cert in CA PEM %s", r.source))
+			caLoaded = false
+		} else {
+			caCert = earliestExpiry(parseCertificates(material.ca))
+		}
+	} else if material.caBundle {
+		slog.Error(fmt.Sprintf("The CA bundle is missing in %s", r.source))
+		caLoaded = false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	swapped := false
+	if caLoaded || r.roots == nil {
+		// The first load uses the system trust store until the CA bundle is loaded
+		r.roots = roots
+		r.caCert = caCert
+		swapped = caLoaded
+	}
+	if len(cert.Certificate) > 0 {
+		swapped = swapped || r.cert == nil || !bytes.Equal(r.cert.Certificate[0], cert.Certificate[0])
+		r.cert = &cert
+	}
+	if loaded && caLoaded {
+		r.digest = digest
+	}
+	if swapped {
+		// The server chains are recorded again by the handshakes with the new certificates
+		r.servers = nil
+	}
+	return swapped
+}
+
+// onChange registers the function which is called after the certificates are reloaded
+func (r *certReloader) onChange(fn func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.listeners = append(r.listeners, fn)
+}
+
+// tlsConfig returns the client configuration which uses the current certificates on every handshake.
+// The standard verification is replaced by verifyConnection, because RootCAs can't be changed after the start.
+// The connections must be created with tlsConfigFor, so the dialed host is verified.
+func (r *certReloader) tlsConfig(opts tlsOptions) *tls.Config {
+	return &tls.Config{
+		InsecureSkipVerify:   true,
+		GetClientCertificate: r.clientCertificate,
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			if opts.insecureSkipVerify {
+				r.observe(cs)
+			} else if err := r.verifyConnection(cs); err != nil {
+				return err
+			}
+			return verifyPins(cs, opts.pins)
+		},
+		MinVersion:   opts.minVersion,
+		CipherSuites: opts.cipherSuites,
+		ServerName:   opts.serverName,
+	}
+}
+
+func (r *certReloader) clientCertificate(_ *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.cert == nil {
+		// The empty certificate means that the client certificate is not sent
+		return &tls.Certificate{}, nil
+	}
+	return r.cert, nil
+}
+
+// verifyConnection verifies the server chain and the host name like the standard verification with the current CA bundle
+func (r *certReloader) verifyConnection(cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("tls: server didn't provide a certificate")
+	}
+	r.mu.RLock()
+	roots := r.roots
+	r.mu.RUnlock()
+	r.observe(cs)
+	intermediates := x509.NewCertPool()
+	for _, cert := range cs.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := cs.PeerCertificates[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		DNSName:       cs.ServerName,
+	})
+	if err != nil {
+		return &tls.CertificateVerificationError{UnverifiedCertificates: cs.PeerCertificates, Err: err}
+	}
+	return nil
+}
+
+// tlsConfigFor returns the configuration of the connection to the dialed host. The handshake doesn't keep
+// the IP address in the server name of the connection state, because the IP address isn't sent in SNI,
+// so the dialed host name or IP address is passed to the verification explicitly.
+// The server name of the configuration from -tlsServerName is kept.
+func tlsConfigFor(config *tls.Config, host string) *tls.Config {
+	c := config.Clone()
+	if c.ServerName == "" {
+		c.ServerName = host
+	}
+	expected := c.ServerName
+	verify := c.VerifyConnection
+	if verify != nil {
+		c.VerifyConnection = func(cs tls.ConnectionState) error {
+			if cs.ServerName == "" {
+				cs.ServerName = expected
+			}
+			return verify(cs)
+		}
+	}
+	return c
+}
+
+// observe keeps the server chain of the handshake for the expiry monitoring
+func (r *certReloader) observe(cs tls.ConnectionState) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.servers == nil {
+		r.servers = map[string]*x509.Certificate{}
+	}
+	r.servers[cs.ServerName] = earliestExpiry(cs.PeerCertificates)
+}
+
+// start watches the source and reloads the certificates after the changes
+func (r *certReloader) start() {
+	r.source.watch(func() {
+		if !r.reload() {
+			return
+		}
+		slog.Info(fmt.Sprintf("TLS certificates are reloaded from %s", r.source))
+		r.mu.RLock()
+		listeners := r.listeners
+		r.mu.RUnlock()
+		for _, fn := range listeners {
+			fn()
+		}
+	})
+}
+
+// close stops watching the source
+func (r *certReloader) close() {
+	r.source.close()
+}
+
+// tlsFiles reads the TLS material from the files, for example from the mounted Secret
+type tlsFiles struct {
+	caPath  string
+	crtPath string
+	keyPath string
+
+	watcher *fsnotify.Watcher
+	done    chan struct{}
+}
+
+func (f *tlsFiles) read() (tlsMaterial, error) {
+	material := tlsMaterial{clientCert: f.crtPath != "", caBundle: f.caPath != ""}
+	var problems []error
+	for _, file := range []struct {
+		path string
+		data *[]byte
+	}{{f.caPath, &material.ca}, {f.crtPath, &material.crt}, {f.keyPath, &material.key}} {
+		if file.path == "" {
+			continue
+		}
+		data, err := os.ReadFile(file.path)
+		if err != nil {
+			problems = append(problems, err)
+		}
+		*file.data = data
+	}
+	return material, errors.Join(problems...)
+}
+
+// watch watches the directories of the files, the mounted Secret is updated by replacing the ..data symlink,
+// so the events of the files themselves are not sent
+func (f *tlsFiles) watch(changed func()) {
+	if f.caPath == "" && f.crtPath == "" {
+		// Nothing to watch with the system trust store only
+		return
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		slog.Error(fmt.Sprintf("Can't watch the certificate files: %s", err.Error()))
+		return
+	}
+	dirs := map[string]bool{}
+	for _, path := range []string{f.caPath, f.crtPath, f.keyPath} {
+		if path == "" || dirs[filepath.Dir(path)] {
+			continue
+		}
+		dirs[filepath.Dir(path)] = true
+		if err := watcher.Add(filepath.Dir(path)); err != nil {
+			slog.Error(fmt.Sprintf("Can't watch the certificate directory '%s': %s", filepath.Dir(path), err.Error()))
+		}
+	}
+	f.watcher = watcher
+	f.done = make(chan struct{})
+	go func() {
+		defer close(f.done)
+		for {
+			select {
+			case _, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				changed()
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				slog.Error(fmt.Sprintf("Error watching the certificate files: %s", err.Error()))
+			}
+		}
+	}()
+}
+
+func (f *tlsFiles) close() {
+	if f.watcher == nil {
+		return
+	}
+	if err := f.watcher.Close(); err != nil {
+		slog.Error(err.Error())
+	}
+	<-f.done
+	f.watcher = nil
+}
+
+func (f *tlsFiles) String() string {
+	var paths []string
+	for _, path := range []string{f.caPath, f.crtPath, f.keyPath} {
+		if path != "" {
+			paths = append(paths, "'"+path+"'")
+		}
+	}
+	if len(paths) == 0 {
+		return "the system trust store"
+	}
+	return "files " + strings.Join(paths, ", ")
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// copyFile replaces the file like the kubelet does for the mounted Secret
+func copyFile(t *testing.T, from string, to string) {
+	data, err := os.ReadFile(from)
+	if err != nil {
+		t.Fatalf("read %s: %v", from, err)
+	}
+	tmp := to + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		t.Fatalf("write %s: %v", tmp, err)
+	}
+	if err := os.Rename(tmp, to); err != nil {
+		t.Fatalf("rename %s: %v", tmp, err)
+	}
+}
+
+func TestCertReloader_ReloadsRotatedCertificate(t *testing.T) {
+	dir := t.TempDir()
+	crt, key := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	firstCrt, firstKey := generateSelfSignedCert(t)
+	copyFile(t, firstCrt, crt)
+	copyFile(t, firstKey, key)
+
+	certs := newCertReloader(crt, crt, key)
+	changed := make(chan struct{}, 1)
+	certs.onChange(func() { changed <- struct{}{} })
+	certs.start()
+	defer certs.close()
+
+	first, _ := certs.clientCertificate(nil)
+	if len(first.Certificate) != 1 {
+		t.Fatalf("expected the client certificate to be loaded")
+	}
+
+	secondCrt, secondKey := generateSelfSignedCert(t)
+	copyFile(t, secondKey, key)
+	copyFile(t, secondCrt, crt)
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case <-changed:
+		case <-deadline:
+			t.Fatal("the rotated certificate is not reloaded")
+		}
+		second, _ := certs.clientCertificate(nil)
+		if string(second.Certificate[0]) != string(first.Certificate[0]) {
+			return
+		}
+	}
+}
+
+func TestCertReloader_KeepsCertificateOnInvalidFiles(t *testing.T) {
+	dir := t.TempDir()
+	crt, key := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	validCrt, validKey := generateSelfSignedCert(t)
+	copyFile(t, validCrt, crt)
+	copyFile(t, validKey, key)
+	certs := newCertReloader(crt, crt, key)
+
+	if err := os.WriteFile(key, []byte("not a key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	certs.reload()
+	cert, _ := certs.clientCertificate(nil)
+	if len(cert.Certificate) != 1 {
+		t.Fatal("expected the previous certificate to be kept")
+	}
+}
+
+func TestCertReloader_DynamicRootPool(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	ca := filepath.Join(dir, "ca.crt")
+	otherCrt, key := generateSelfSignedCert(t)
+	copyFile(t, otherCrt, ca)
+
+	hc := createHttpClient("u", "p", true, tlsOptions{caPath: ca, crtPath: otherCrt, keyPath: key}, 1)
+	defer hc.certs.close()
+	if _, err := hc.client.Get(srv.URL); err == nil {
+		t.Fatal("expected the verification error with the unknown CA")
+	}
+
+	serverCA := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(ca+".tmp", serverCA, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(ca+".tmp", ca); err != nil {
+		t.Fatal(err)
+	}
+	// The reload is triggered directly to avoid waiting for the watcher
+	hc.certs.reload()
+	res, err := hc.client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("expected the connection with the reloaded CA, got %v", err)
+	}
+	_ = res.Body.Close()
+}
+
+func TestCertReloader_VerifyConnectionHostName(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	dir := t.TempDir()
+	ca := filepath.Join(dir, "ca.crt")
+	if err := os.WriteFile(ca, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	certs := newCertReloader(ca, "", "")
+	conn, err := tls.Dial("tcp", srv.Listener.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	cs := conn.ConnectionState()
+	cs.ServerName = "other.host"
+	if err := certs.verifyConnection(cs); err == nil {
+		t.Error("expected the host name error")
+	}
+	cs.ServerName = "127.0.0.1"
+	if err := certs.verifyConnection(cs); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// serveCertificate starts the TLS server with the self-signed certificate for the names and returns the path of the certificate as the CA
+func serveCertificate(t *testing.T, dnsNames []string, ips []net.IP) (*httptest.Server, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:         true,
+		DNSNames:     dnsNames,
+		IPAddresses:  ips,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	ca := filepath.Join(t.TempDir(), "ca.crt")
+	if err := os.WriteFile(ca, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return srv, ca
+}
+
+func TestCertReloader_VerifiesIPAddress(t *testing.T) {
+	srv, ca := serveCertificate(t, []string{"other.example"}, nil)
+
+	hc := createHttpClient("u", "p", true, tlsOptions{caPath: ca}, 1)
+	defer hc.certs.close()
+	res, err := hc.client.Get(srv.URL)
+	if err == nil {
+		_ = res.Body.Close()
+		t.Fatal("expected the certificate for other.example to be rejected for 127.0.0.1")
+	}
+	if !strings.Contains(err.Error(), "127.0.0.1") {
+		t.Errorf("expected the IP address in the error, got %v", err)
+	}
+
+	// Cassandra and gRPC connections are created by tlsConfigFor with the dialed host
+	config := tlsConfigFor(hc.certs.tlsConfig(tlsOptions{}), "127.0.0.1")
+	if conn, err := tls.Dial("tcp", srv.Listener.Addr().String(), config); err == nil {
+		_ = conn.Close()
+		t.Fatal("expected the certificate for other.example to be rejected for 127.0.0.1")
+	}
+}
+
+func TestCertReloader_AcceptsIPAddressSAN(t *testing.T) {
+	srv, ca := serveCertificate(t, nil, []net.IP{net.ParseIP("127.0.0.1")})
+
+	hc := createHttpClient("u", "p", true, tlsOptions{caPath: ca}, 1)
+	defer hc.certs.close()
+	res, err := hc.client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("expected the IP SAN to match, got %v", err)
+	}
+	_ = res.Body.Close()
+}
+
+func TestCertReloader_KeepsRootsOnInvalidCA(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	ca := writeServerCA(t, srv)
+	hc := createHttpClient("u", "p", true, tlsOptions{caPath: ca}, 1)
+	defer hc.certs.close()
+	roots, digest := hc.certs.roots, hc.certs.digest
+
+	for _, broken := range []func() error{
+		func() error { return os.WriteFile(ca, []byte("not a certificate"), 0o600) },
+		func() error { return os.Remove(ca) },
+	} {
+		if err := broken(); err != nil {
+			t.Fatal(err)
+		}
+		if hc.certs.reload() {
+			t.Error("expected the invalid CA bundle not to be swapped")
+		}
+		if hc.certs.roots != roots || hc.certs.digest != digest {
+			t.Fatal("expected the previous CA bundle and digest to be kept")
+		}
+		hc.client.CloseIdleConnections()
+		res, err := hc.client.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("expected the connection with the previous CA bundle, got %v", err)
+		}
+		_ = res.Body.Close()
+	}
+}
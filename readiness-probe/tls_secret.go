@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// defaultCAKey is the key of the CA bundle in the kubernetes.io/tls Secret and in the cert-manager CA Secret
+const defaultCAKey = "ca.crt"
+
+// tlsSecrets reads the TLS material from the kubernetes.io/tls Secret and the CA bundle from the Secret or the ConfigMap,
+// the objects are watched and the material is kept in memory
+type tlsSecrets struct {
+	namespace       string
+	secretName      string
+	caSecretName    string
+	caConfigMapName string
+	caKey           string
+	newClient       func() (kubernetes.Interface, error)
+
+	mu     sync.Mutex
+	client kubernetes.Interface
+	loaded bool
+	// secret and ca are the latest data of the TLS Secret and the CA Secret or ConfigMap
+	secret map[string][]byte
+	ca     []byte
+	stop   chan struct{}
+}
+
+func newTLSSecrets(opts tlsOptions, newClient func() (kubernetes.Interface, error)) *tlsSecrets {
+	caKey := opts.caKey
+	if caKey == "" {
+		caKey = defaultCAKey
+	}
+	return &tlsSecrets{
+		namespace:       opts.namespace,
+		secretName:      opts.secretName,
+		caSecretName:    opts.caSecretName,
+		caConfigMapName: opts.caConfigMapName,
+		caKey:           caKey,
+		newClient:       newClient,
+	}
+}
+
+// read returns the material, the objects are read from the API until the watch is started
+func (s *tlsSecrets) read() (tlsMaterial, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var err error
+	if !s.loaded {
+		err = s.load(context.TODO())
+	}
+	material := tlsMaterial{crt: s.secret[v1.TLSCertKey], key: s.secret[v1.TLSPrivateKeyKey], ca: s.ca, clientCert: s.secretName != "",
+		caBundle: s.caSecretName != "" || s.caConfigMapName != ""}
+	if s.caSecretName == "" && s.caConfigMapName == "" {
+		// The Secret issued by cert-manager contains the CA of the issuer
+		material.ca = s.secret[s.caKey]
+	}
+	return material, err
+}
+
+func (s *tlsSecrets) load(ctx context.Context) error {
+	if s.client == nil {
+		client, err := s.newClient()
+		if err != nil {
+			return fmt.Errorf("can't read the TLS certificates from %s: %w", s, err)
+		}
+		s.client = client
+	}
+	var problems []error
+	if s.secretName != "" {
+		if secret, err := s.client.CoreV1().Secrets(s.namespace).Get(ctx, s.secretName, metaV1.GetOptions{}); err != nil {
+			problems = append(problems, fmt.Errorf("can't read the secret '%s/%s': %w", s.namespace, s.secretName, err))
+		} else {
+			s.secret = secret.Data
+		}
+	}
+	if s.caSecretName != "" {
+		if secret, err := s.client.CoreV1().Secrets(s.namespace).Get(ctx, s.caSecretName, metaV1.GetOptions{}); err != nil {
+			problems = append(problems, fmt.Errorf("can't read the secret '%s/%s': %w", s.namespace, s.caSecretName, err))
+		} else {
+			s.ca = secret.Data[s.caKey]
+		}
+	}
+	if s.caConfigMapName != "" {
+		if configMap, err := s.client.CoreV1().ConfigMaps(s.namespace).Get(ctx, s.caConfigMapName, metaV1.GetOptions{}); err != nil {
+			problems = append(problems, fmt.Errorf("can't read the configmap '%s/%s': %w", s.namespace, s.caConfigMapName, err))
+		} else {
+			s.ca = []byte(configMap.Data[s.caKey])
+		}
+	}
+	// The missing objects are read again on the next reload until they are created
+	s.loaded = len(problems) == 0
+	return errors.Join(problems...)
+}
+
+// watch runs the informers of the objects, the informers list the objects again after the watch is broken
+func (s *tlsSecrets) watch(changed func()) {
+	s.mu.Lock()
+	client := s.client
+	s.mu.Unlock()
+	if client == nil {
+		slog.Error(fmt.Sprintf("Can't watch the TLS certificates from %s without the Kubernetes client", s))
+		return
+	}
+	s.stop = make(chan struct{})
+	if s.secretName != "" {
+		s.watchObject(client, "secret", s.secretName, func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+			return f.Core().V1().Secrets().Informer()
+		}, func(obj interface{}) bool {
+			secret, ok := obj.(*v1.Secret)
+			if ok {
+				s.secret = secret.Data
+			}
+			return ok
+		}, changed)
+	}
+	if s.caSecretName != "" {
+		s.watchObject(client, "secret", s.caSecretName, func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+			return f.Core().V1().Secrets().Informer()
+		}, func(obj interface{}) bool {
+			secret, ok := obj.(*v1.Secret)
+			if ok {
+				s.ca = secret.Data[s.caKey]
+			}
+			return ok
+		}, changed)
+	}
+	if s.caConfigMapName != "" {
+		s.watchObject(client, "configmap", s.caConfigMapName, func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+			return f.Core().V1().ConfigMaps().Informer()
+		}, func(obj interface{}) bool {
+			configMap, ok := obj.(*v1.ConfigMap)
+			if ok {
+				s.ca = []byte(configMap.Data[s.caKey])
+			}
+			return ok
+		}, changed)
+	}
+	slog.Info(fmt.Sprintf("Watching %s for the TLS certificates changes", s))
+}
+
+// watchObject runs the informer of the single object, update stores the data of the object under the lock
+func (s *tlsSecrets) watchObject(client kubernetes.Interface, kind string, name string, informer func(informers.SharedInformerFactory) cache.SharedIndexInformer,
+	update func(obj interface{}) bool, changed func()) {
+	factory := informers.NewSharedInformerFactoryWithOptions(client, 0,
+		informers.WithNamespace(s.namespace),
+		informers.WithTweakListOptions(func(opts *metaV1.ListOptions) {
+			opts.FieldSelector = fields.OneTermEqualSelector("metadata.name", name).String()
+		}))
+	onObject := func(obj interface{}) {
+		s.mu.Lock()
+		ok := update(obj)
+		s.mu.Unlock()
+		if ok {
+			changed()
+		}
+	}
+	_, err := informer(factory).AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: onObject,
+		UpdateFunc: func(_, obj interface{}) {
+			onObject(obj)
+		},
+		DeleteFunc: func(_ interface{}) {
+			slog.Warn(fmt.Sprintf("The %s '%s/%s' is deleted, the last TLS certificates are kept", kind, s.namespace, name))
+		},
+	})
+	if err != nil {
+		slog.Error(fmt.Sprintf("Can't watch the %s '%s/%s': %s", kind, s.namespace, name, err.Error()))
+		return
+	}
+	factory.Start(s.stop)
+}
+
+// close stops the informers
+func (s *tlsSecrets) close() {
+	if s.stop != nil {
+		close(s.stop)
+		s.stop = nil
+	}
+}
+
+func (s *tlsSecrets) String() string {
+	var objects []string
+	if s.secretName != "" {
+		objects = append(objects, fmt.Sprintf("secret '%s/%s'", s.namespace, s.secretName))
+	}
+	if s.caSecretName != "" {
+		objects = append(objects, fmt.Sprintf("secret '%s/%s'", s.namespace, s.caSecretName))
+	}
+	if s.caConfigMapName != "" {
+		objects = append(objects, fmt.Sprintf("configmap '%s/%s'", s.namespace, s.caConfigMapName))
+	}
+	return strings.Join(objects, " and ")
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func tlsSecret(t *testing.T, name string) *v1.Secret {
+	crt, key := generateSelfSignedCert(t)
+	return &v1.Secret{
+		ObjectMeta: metaV1.ObjectMeta{Namespace: "tracing", Name: name},
+		Type:       v1.SecretTypeTLS,
+		Data: map[string][]byte{
+			v1.TLSCertKey:       readFile(t, crt),
+			v1.TLSPrivateKeyKey: readFile(t, key),
+			defaultCAKey:        readFile(t, crt),
+		},
+	}
+}
+
+func readFile(t *testing.T, path string) []byte {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	return data
+}
+
+func TestTLSSecrets_ReloadsRotatedSecret(t *testing.T) {
+	client := fake.NewSimpleClientset(tlsSecret(t, "jaeger-tls"))
+	source := newTLSSecrets(tlsOptions{namespace: "tracing", secretName: "jaeger-tls"},
+		func() (kubernetes.Interface, error) { return client, nil })
+	certs := newSourceReloader(source)
+	first, _ := certs.clientCertificate(nil)
+	if len(first.Certificate) != 1 || certs.caCert == nil {
+		t.Fatalf("expected the certificate and the CA from the secret")
+	}
+	changed := make(chan struct{}, 1)
+	certs.onChange(func() {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	})
+	certs.start()
+	defer certs.close()
+
+	// The fake watch is established asynchronously, so the Secret is updated until the change is seen
+	deadline := time.After(5 * time.Second)
+	for {
+		if _, err := client.CoreV1().Secrets("tracing").Update(context.Background(), tlsSecret(t, "jaeger-tls"), metaV1.UpdateOptions{}); err != nil {
+			t.Fatalf("update secret: %v", err)
+		}
+		select {
+		case <-changed:
+			second, _ := certs.clientCertificate(nil)
+			if string(second.Certificate[0]) == string(first.Certificate[0]) {
+				t.Fatal("expected the rotated certificate")
+			}
+			return
+		case <-time.After(100 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("the rotated secret is not reloaded")
+		}
+	}
+}
+
+func TestTLSSecrets_CAFromConfigMap(t *testing.T) {
+	secret := tlsSecret(t, "jaeger-tls")
+	configMap := &v1.ConfigMap{
+		ObjectMeta: metaV1.ObjectMeta{Namespace: "tracing", Name: "kube-root-ca.crt"},
+		Data:       map[string]string{"service-ca.crt": string(secret.Data[defaultCAKey])},
+	}
+	delete(secret.Data, defaultCAKey)
+	client := fake.NewSimpleClientset(configMap)
+	source := newTLSSecrets(tlsOptions{namespace: "tracing", caConfigMapName: "kube-root-ca.crt", caKey: "service-ca.crt"},
+		func() (kubernetes.Interface, error) { return client, nil })
+	material, err := source.read()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(material.ca) == 0 || material.clientCert || len(material.crt) != 0 {
+		t.Errorf("expected only the CA from the configmap, got %+v", material)
+	}
+	if source.String() != "configmap 'tracing/kube-root-ca.crt'" {
+		t.Errorf("unexpected source %s", source)
+	}
+}
+
+func TestTLSSecrets_MissingSecret(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	source := newTLSSecrets(tlsOptions{namespace: "tracing", secretName: "jaeger-tls", caSecretName: "jaeger-ca"},
+		func() (kubernetes.Interface, error) { return client, nil })
+	if _, err := source.read(); err == nil {
+		t.Fatal("expected error for the missing secrets")
+	}
+	if _, err := client.CoreV1().Secrets("tracing").Create(context.Background(), tlsSecret(t, "jaeger-tls"), metaV1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	ca := tlsSecret(t, "jaeger-ca")
+	if _, err := client.CoreV1().Secrets("tracing").Create(context.Background(), ca, metaV1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	material, err := source.read()
+	if err != nil || !material.clientCert || string(material.ca) != string(ca.Data[defaultCAKey]) {
+		t.Fatalf("expected the secrets to be read again, got %v", err)
+	}
+}